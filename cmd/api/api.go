@@ -6,6 +6,7 @@ import (
 	"expvar"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,7 +14,9 @@ import (
 
 	"github.com/NikolayProkopchuk/social/docs" // This line is used by Swag CLI to generate docs
 	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/events"
 	"github.com/NikolayProkopchuk/social/internal/mailer"
+	"github.com/NikolayProkopchuk/social/internal/ranking"
 	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/NikolayProkopchuk/social/internal/store/cache"
@@ -33,18 +36,29 @@ type application struct {
 	authenticator auth.Authenticator
 	cache         *cache.Cache
 	rateLimiter   ratelimiter.Limiter
+	oidc          auth.IdentityProvider
+	events        events.Broker
 }
 
 type config struct {
-	address     string
-	db          *dbConfig
-	env         string
-	apiUrl      string
-	mail        *mailConfig
-	frontednURL string
-	auth        *authConfig
-	redis       *redisConfig
-	rateLimiter *ratelimiter.Config
+	address       string
+	db            *dbConfig
+	env           string
+	apiUrl        string
+	mail          *mailConfig
+	frontednURL   string
+	auth          *authConfig
+	redis         *redisConfig
+	rateLimiter   *ratelimiter.Config
+	loginThrottle *loginThrottleConfig
+	oidc          *oidcConfig
+	account       *accountConfig
+	timeline      *timelineConfig
+	ranking       *rankingConfig
+	events        *eventsConfig
+	comments      *commentsConfig
+	// trustedProxies are the proxies whose forwarding headers tell the address of the client
+	trustedProxies []netip.Prefix
 }
 
 type dbConfig struct {
@@ -55,9 +69,10 @@ type dbConfig struct {
 }
 
 type mailConfig struct {
-	sendgrid  sendgridConfig
-	fromEmail string
-	exp       time.Duration
+	sendgrid         sendgridConfig
+	fromEmail        string
+	exp              time.Duration
+	passwordResetExp time.Duration
 }
 
 type sendgridConfig struct {
@@ -70,10 +85,16 @@ type authConfig struct {
 }
 
 type tokenConfig struct {
-	secret   string
-	audience string
-	issuer   string
-	exp      time.Duration
+	secret     string
+	audience   string
+	issuer     string
+	exp        time.Duration
+	refreshExp time.Duration
+	mfaExp     time.Duration
+	// mfaAttempts is the number of wrong codes after which a two-factor challenge is revoked
+	mfaAttempts  int
+	signingKeys  string
+	signingKeyID string
 }
 
 type basicAuth struct {
@@ -81,6 +102,46 @@ type basicAuth struct {
 	password string
 }
 
+type oidcConfig struct {
+	enabled      bool
+	provider     string
+	issuerURL    string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+}
+
+type accountConfig struct {
+	// deletionPolicy is store.DeletionPolicyAnonymize or store.DeletionPolicyCascade
+	deletionPolicy string
+}
+
+type timelineConfig struct {
+	// maxLength is the number of the newest posts kept in a timeline
+	maxLength int
+	// celebrityFollowers is the number of followers from which the posts of a user are read
+	// from the follows instead of being pushed to the timelines of the followers
+	celebrityFollowers int
+}
+
+type rankingConfig struct {
+	weights ranking.Weights
+	// window is how far back the posts of the ranked feed go
+	window time.Duration
+	// candidates is the number of the newest posts in the window that are ranked
+	candidates int
+}
+
+type commentsConfig struct {
+	// maxDepth is how deep replies nest, top-level comments being at depth 0
+	maxDepth int
+}
+
+type eventsConfig struct {
+	// broker is "memory" for a single instance of the API or "redis" to share events between instances
+	broker string
+}
+
 type redisConfig struct {
 	addr     string
 	password string
@@ -95,50 +156,121 @@ func (app *application) mount() http.Handler {
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(app.realIPMiddleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
 	r.Use(app.rateLimiterMiddleware)
 	r.Route("/v1", func(r chi.Router) {
-		r.Get("/health", app.healthCheckHandler)
-		r.With(app.basicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
-
-		docsUrl := fmt.Sprintf("%s/swagger/doc.json", app.config.address)
-		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsUrl)))
-		r.Route("/posts", func(r chi.Router) {
-			r.Use(app.authTokentMiddleware)
-			r.Post("/", app.createPostHandler)
-			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postsContextMiddleware)
-				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.postOwnershipMiddleware("moderator", app.updatePostHandler))
-				r.Delete("/", app.postOwnershipMiddleware("admin", app.deletePostHandler))
-
-				r.Route("/comments", func(r chi.Router) {
-					r.Post("/", app.createCommentHandler)
+		// event streams stay open for longer than the timeout of the other requests
+		r.With(app.scopedAuthMiddleware(store.ScopeFeedRead)).Get("/users/feed/stream", app.feedStreamHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(60 * time.Second))
+			r.Get("/health", app.healthCheckHandler)
+			r.Get("/.well-known/jwks.json", app.jwksHandler)
+			r.With(app.basicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
+
+			docsUrl := fmt.Sprintf("%s/swagger/doc.json", app.config.address)
+			r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsUrl)))
+			r.Route("/posts", func(r chi.Router) {
+				r.With(app.scopedAuthMiddleware(store.ScopePostsWrite)).Post("/", app.createPostHandler)
+				r.Route("/{postID}", func(r chi.Router) {
+					r.Group(func(r chi.Router) {
+						r.Use(app.scopedAuthMiddleware(store.ScopeFeedRead), app.postsContextMiddleware)
+						r.Get("/", app.getPostHandler)
+						r.Get("/comments", app.getCommentsHandler)
+					})
+					r.Group(func(r chi.Router) {
+						r.Use(app.scopedAuthMiddleware(store.ScopePostsWrite), app.postsContextMiddleware)
+						r.Patch("/", app.postOwnershipMiddleware("moderator", app.updatePostHandler))
+						r.Delete("/", app.postOwnershipMiddleware("admin", app.deletePostHandler))
+						r.Put("/reactions", app.setReactionHandler)
+						r.Delete("/reactions", app.deleteReactionHandler)
+					})
+					r.Group(func(r chi.Router) {
+						r.Use(app.scopedAuthMiddleware(store.ScopeCommentsWrite), app.postsContextMiddleware)
+						r.Post("/comments", app.createCommentHandler)
+						r.With(app.commentsContextMiddleware).Patch("/comments/{commentID}", app.commentOwnershipMiddleware("moderator", false, app.updateCommentHandler))
+						r.With(app.commentsContextMiddleware).Delete("/comments/{commentID}", app.commentOwnershipMiddleware("admin", true, app.deleteCommentHandler))
+					})
+					r.With(app.authTokentMiddleware, app.postsContextMiddleware, app.commentsContextMiddleware).
+						Get("/comments/{commentID}/edits", app.roleMiddleware("moderator", app.getCommentEditsHandler))
 				})
 			})
-		})
 
-		r.Route("/users", func(r chi.Router) {
-			r.Put("/active", app.activateUserHandler)
-			r.Route("/{userID}", func(r chi.Router) {
-				r.Use(app.authTokentMiddleware)
-				r.Get("/", app.userOwnershipMiddleware("moderator", app.getUserHandler))
+			r.Route("/users", func(r chi.Router) {
+				r.Put("/active", app.activateUserHandler)
+				r.Post("/activation/resend", app.resendActivationHandler)
+				r.Route("/{userID}", func(r chi.Router) {
+					r.Group(func(r chi.Router) {
+						r.Use(app.authTokentMiddleware)
+						r.Get("/", app.userOwnershipMiddleware("moderator", app.getUserHandler))
+
+						r.Put("/follow", app.followUserHandler)
+						r.Put("/unfollow", app.unfollowUserHandler)
+						r.Put("/block", app.blockUserHandler)
+						r.Put("/unblock", app.unblockUserHandler)
+						r.Put("/mute", app.muteUserHandler)
+						r.Put("/unmute", app.unmuteUserHandler)
+						r.Delete("/sessions", app.userOwnershipMiddleware("admin", app.revokeUserSessionsHandler))
+					})
+					r.Group(func(r chi.Router) {
+						r.Use(app.optionalAuthMiddleware)
+						r.Get("/followers", app.getUserFollowersHandler)
+						r.Get("/following", app.getUserFollowingHandler)
+					})
+				})
+				r.Route("/me", func(r chi.Router) {
+					r.Use(app.authTokentMiddleware)
+					r.Get("/", app.getCurrentUserHandler)
+					r.Patch("/", app.updateCurrentUserHandler)
+					r.Delete("/", app.deleteCurrentUserHandler)
+					r.Get("/export", app.exportCurrentUserHandler)
+					r.Route("/follow-requests", func(r chi.Router) {
+						r.Get("/", app.getFollowRequestsHandler)
+						r.Put("/{userID}", app.approveFollowRequestHandler)
+						r.Delete("/{userID}", app.rejectFollowRequestHandler)
+					})
+					r.Route("/tokens", func(r chi.Router) {
+						r.Post("/", app.createPersonalTokenHandler)
+						r.Get("/", app.getPersonalTokensHandler)
+						r.Delete("/{tokenID}", app.deletePersonalTokenHandler)
+					})
+				})
+				r.With(app.scopedAuthMiddleware(store.ScopeFeedRead)).Get("/feed", app.getUserFeedHandler)
+			})
+
+			r.Route("/authentication", func(r chi.Router) {
+				r.Post("/user", app.registerUserHandler)
+				r.Post("/token", app.createTokenHandler)
+				r.Post("/refresh", app.refreshTokenHandler)
+				r.With(app.mfaEnrollmentMiddleware).Post("/logout", app.logoutHandler)
+				r.Post("/password/forgot", app.forgotPasswordHandler)
+				r.Post("/password/reset", app.resetPasswordHandler)
 
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Route("/oidc", func(r chi.Router) {
+					r.Get("/login", app.oidcLoginHandler)
+					r.Get("/callback", app.oidcCallbackHandler)
+				})
+
+				r.Route("/mfa", func(r chi.Router) {
+					r.Post("/challenge", app.mfaChallengeHandler)
+					r.With(app.mfaEnrollmentMiddleware).Post("/enroll", app.mfaEnrollHandler)
+					r.With(app.mfaEnrollmentMiddleware).Post("/verify", app.mfaVerifyHandler)
+				})
 			})
-			r.Group(func(r chi.Router) {
+
+			r.Route("/roles", func(r chi.Router) {
 				r.Use(app.authTokentMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.Put("/mfa", app.roleMiddleware("admin", app.requireMFAHandler))
 			})
-		})
 
-		r.Route("/authentication", func(r chi.Router) {
-			r.Post("/user", app.registerUserHandler)
-			r.Post("/token", app.createTokenHandler)
+			r.Route("/lockouts", func(r chi.Router) {
+				r.Use(app.authTokentMiddleware)
+				r.Get("/", app.roleMiddleware("admin", app.getLockoutsHandler))
+				r.Get("/{scope}/{key}", app.roleMiddleware("admin", app.getLoginAttemptHandler))
+				r.Delete("/{scope}/{key}", app.roleMiddleware("admin", app.deleteLoginAttemptHandler))
+			})
 		})
 	})
 
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -17,6 +18,8 @@ func TestRateLimiterMiddleware(t *testing.T) {
 			Enabled:              true,
 		},
 		address: ":8080",
+		// the requests of the test client come through a proxy on the loopback
+		trustedProxies: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
 	}
 
 	app := newTestApp(t, cfg)
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/NikolayProkopchuk/social/internal/auth"
 	"github.com/NikolayProkopchuk/social/internal/mailer"
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/golang-jwt/jwt/v5"
@@ -54,8 +60,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	plainInviteCode := uuid.New().String()
 	app.logger.Infof("Generated invite code for %s: %s", user.Email, plainInviteCode)
-	hash := sha256.Sum256([]byte(plainInviteCode))
-	inviteCode := hex.EncodeToString(hash[:])
+	inviteCode := hashToken(plainInviteCode)
 
 	if err := app.store.Users.CreateAndInvite(r.Context(), user, inviteCode, app.config.mail.exp); err != nil {
 		if errors.Is(err, store.ErrConflict) {
@@ -65,16 +70,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		app.internalServerError(w, r, err)
 		return
 	}
-	isProdEnv := app.config.env == "prodaction"
-	vars := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: app.config.frontednURL + "/activate?code=" + plainInviteCode,
-	}
-
-	if err := app.mailer.Send(mailer.UserInviteTemplate, user.Username, user.Email, vars, !isProdEnv); err != nil {
+	if err := app.sendInvitation(user, plainInviteCode); err != nil {
 		app.logger.Errorw("unable to send email", "error", err)
 		app.internalServerError(w, r, err)
 		return
@@ -86,6 +82,18 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+func (app *application) sendInvitation(user *store.User, plainInviteCode string) error {
+	isProdEnv := app.config.env == "prodaction"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: app.config.frontednURL + "/activate?code=" + plainInviteCode,
+	}
+	return app.mailer.Send(mailer.UserInviteTemplate, user.Username, user.Email, vars, !isProdEnv)
+}
+
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,min=3,max=100"`
 	Password string `json:"password" validate:"required,min=8,max=100"`
@@ -95,14 +103,17 @@ type RegisterUserPayload struct {
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates a token for a user, or a two-factor challenge if the user enabled it
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	TokenResponse			"Access and refresh tokens"
+//	@Success		200		{object}	MFAChallengeResponse	"Two-factor challenge"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		429		{object}	error
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -116,10 +127,22 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	ip := clientIP(r)
+	retryAfter, err := app.loginRetryAfter(r.Context(), payload.Email, ip)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.tooManyLoginAttemptsError(w, r, retryAfter)
+		return
+	}
+
 	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
+			app.recordLoginFailure(r.Context(), payload.Email, ip, nil)
 			app.unauthorizedError(w, r, err)
 			return
 		default:
@@ -129,25 +152,41 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 	log.Printf("User found: %+v", user)
 	if err := user.Password.Compare(payload.Password); err != nil {
+		app.recordLoginFailure(r.Context(), payload.Email, ip, user)
 		app.unauthorizedError(w, r, err)
 		return
 	}
+	app.resetLoginFailures(r.Context(), payload.Email)
+	app.completeLogin(w, r, user)
+}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"aud": app.config.auth.tokenCfg.audience,
-		"iss": app.config.auth.tokenCfg.issuer,
-		"exp": time.Now().Add(app.config.auth.tokenCfg.exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-	}
-	token, err := app.authenticator.GenerateToken(claims)
+// completeLogin responds with a token pair for a user whose credentials were verified,
+// or with a two-factor challenge when the user has enabled it.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, user *store.User) {
+	if !user.Active {
+		app.resourceForbiddenError(w, r, errUserNotActivated)
+		return
+	}
+	if user.MFAEnabled {
+		mfaToken, err := app.generateMFAToken(user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		challenge := &MFAChallengeResponse{MFARequired: true, MFAToken: mfaToken}
+		if err := app.jsonResponse(w, http.StatusOK, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.issueTokens(r.Context(), user)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -156,3 +195,230 @@ type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8,max=100"`
 }
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Rotates a refresh token and issues a new access and refresh token pair
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	TokenResponse		"Access and refresh tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	plainRefreshToken, err := generateRandomToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	next := &store.RefreshToken{
+		TokenHash: hashToken(plainRefreshToken),
+		ExpiresAt: time.Now().Add(app.config.auth.tokenCfg.refreshExp),
+	}
+	if err := app.store.RefreshTokens.Rotate(r.Context(), hashToken(payload.RefreshToken), next); err != nil {
+		var reused *store.TokenReusedError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedError(w, r, fmt.Errorf("invalid refresh token"))
+		case errors.As(err, &reused):
+			app.logger.Warnw("refresh token reuse detected", "userID", reused.UserID, "familyID", reused.FamilyID)
+			app.unauthorizedError(w, r, fmt.Errorf("refresh token reuse detected, session revoked"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.getUser(r.Context(), next.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if !user.Active {
+		app.resourceForbiddenError(w, r, errUserNotActivated)
+		return
+	}
+	accessToken, err := app.generateAccessToken(user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: plainRefreshToken,
+		ExpiresIn:    int64(app.config.auth.tokenCfg.exp.Seconds()),
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=100"`
+}
+
+// jwksHandler godoc
+//
+//	@Summary		Fetches the JSON Web Key Set
+//	@Description	Fetches the public keys used to verify tokens when they are signed with asymmetric keys
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKSet
+//	@Failure		404	{object}	error
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.authenticator.(auth.KeySetProvider)
+	if !ok {
+		app.resourceNotFound(w, r, fmt.Errorf("tokens are not signed with asymmetric keys"))
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := writeJSON(w, http.StatusOK, provider.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// issueTokens creates an access token and starts a new refresh token family for the user.
+func (app *application) issueTokens(ctx context.Context, user *store.User) (*TokenResponse, error) {
+	accessToken, err := app.generateAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+	plainRefreshToken, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken := &store.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  uuid.New().String(),
+		TokenHash: hashToken(plainRefreshToken),
+		ExpiresAt: time.Now().Add(app.config.auth.tokenCfg.refreshExp),
+	}
+	if err := app.store.RefreshTokens.Create(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: plainRefreshToken,
+		ExpiresIn:    int64(app.config.auth.tokenCfg.exp.Seconds()),
+	}, nil
+}
+
+func (app *application) generateAccessToken(user *store.User) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"jti":               uuid.New().String(),
+		"sub":               user.ID,
+		"aud":               app.config.auth.tokenCfg.audience,
+		"iss":               app.config.auth.tokenCfg.issuer,
+		"exp":               now.Add(app.config.auth.tokenCfg.exp).Unix(),
+		"iat":               now.Unix(),
+		issuedAtMicrosClaim: now.UnixMicro(),
+		"nbf":               now.Unix(),
+	}
+	return app.authenticator.GenerateToken(claims)
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out a user
+//	@Description	Revokes the access token used for the request and, if provided, the refresh token family
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		LogoutPayload	false	"Refresh token to revoke"
+//	@Success		204		{string}	string			"Logged out"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload LogoutPayload
+	if err := readJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	claims := app.getClaimsFromContext(r)
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		app.unauthorizedError(w, r, fmt.Errorf("invalid exp claim value"))
+		return
+	}
+	jti, _ := claims["jti"].(string)
+	if err := app.tokenDenylist().Revoke(r.Context(), jti, exp.Time); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if payload.RefreshToken != "" {
+		if err := app.store.RefreshTokens.Revoke(r.Context(), hashToken(payload.RefreshToken)); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.noContentResponse(w)
+}
+
+type LogoutPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"omitempty,max=100"`
+}
+
+func (app *application) getClaimsFromContext(r *http.Request) jwt.MapClaims {
+	claims, _ := r.Context().Value(claimsContextKey).(jwt.MapClaims)
+	return claims
+}
+
+// revokeUserSessions invalidates every access token issued to the user so far
+// and all of the user's refresh token families.
+func (app *application) revokeUserSessions(ctx context.Context, userID int64) error {
+	if err := app.tokenDenylist().RevokeUser(ctx, userID, time.Now().Add(app.config.auth.tokenCfg.exp)); err != nil {
+		return err
+	}
+	return app.store.RefreshTokens.RevokeAllForUser(ctx, userID)
+}
+
+func generateRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRefreshToken(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		auth: &authConfig{
+			tokenCfg: tokenConfig{
+				exp:        time.Hour,
+				refreshExp: time.Hour * 24,
+			},
+		},
+	}
+
+	test := []struct {
+		name           string
+		refreshToken   string
+		rotateErr      error
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should reject unknown refresh token",
+			refreshToken:   "unknown",
+			rotateErr:      store.ErrNotFound,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody: map[string]any{
+				"error": "invalid refresh token",
+			},
+		},
+		{
+			name:           "should reject reused refresh token",
+			refreshToken:   "reused",
+			rotateErr:      &store.TokenReusedError{UserID: 2, FamilyID: "family"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody: map[string]any{
+				"error": "refresh token reuse detected, session revoked",
+			},
+		},
+		{
+			name:           "should issue a new token pair",
+			refreshToken:   "valid",
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range test {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestApp(t, cfg)
+			mux := app.mount()
+
+			mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+			mockRefreshTokenStore.On("Rotate", mock.Anything, hashToken(tc.refreshToken), mock.Anything).
+				Run(func(args mock.Arguments) {
+					args.Get(2).(*store.RefreshToken).UserID = 2
+				}).
+				Return(tc.rotateErr)
+
+			body, err := json.Marshal(RefreshTokenPayload{RefreshToken: tc.refreshToken})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest("POST", "/v1/authentication/refresh", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			core, logs := observer.New(zap.WarnLevel)
+			app.logger = zap.New(core).Sugar()
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			var reused *store.TokenReusedError
+			if errors.As(tc.rotateErr, &reused) {
+				// the session that was revoked is logged
+				entries := logs.FilterMessage("refresh token reuse detected").All()
+				if assert.Len(t, entries, 1) {
+					fields := entries[0].ContextMap()
+					assert.Equal(t, int64(2), fields["userID"])
+					assert.Equal(t, "family", fields["familyID"])
+				}
+			}
+			if tc.expectedBody != nil {
+				checkResponseBody(t, tc.expectedBody, rr.Body.Bytes())
+				return
+			}
+
+			var got struct {
+				Data TokenResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			assert.NotEmpty(t, got.Data.AccessToken)
+			assert.NotEmpty(t, got.Data.RefreshToken)
+			assert.NotEqual(t, tc.refreshToken, got.Data.RefreshToken)
+			assert.Equal(t, int64(time.Hour.Seconds()), got.Data.ExpiresIn)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+
+	t.Run("should revoke the access token and the refresh token family", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+		mockRevokedTokenStore.On("Revoke", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(nil)
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.On("Revoke", mock.Anything, hashToken("refresh")).Return(nil)
+
+		body, err := json.Marshal(LogoutPayload{RefreshToken: "refresh"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/v1/authentication/logout", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+		mockRevokedTokenStore.AssertNumberOfCalls(t, "Revoke", 1)
+		mockRefreshTokenStore.AssertNumberOfCalls(t, "Revoke", 1)
+	})
+
+	t.Run("should reject a revoked access token", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+		mockRevokedTokenStore.ExpectedCalls = nil
+		mockRevokedTokenStore.On("IsRevoked", mock.Anything, mock.Anything, int64(1), mock.Anything).Return(true, nil)
+
+		req, err := http.NewRequest("GET", "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		checkResponseBody(t, map[string]any{"error": "token has been revoked"}, rr.Body.Bytes())
+
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.AssertNumberOfCalls(t, "GetByID", 0)
+	})
+}
+
+func TestCreateTokenInactiveUser(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+
+	inactiveUser := &store.User{
+		ID:       4,
+		Username: "InactiveUser",
+		Email:    "inactive@mail.com",
+	}
+	if err := inactiveUser.Password.Set("password"); err != nil {
+		t.Fatal(err)
+	}
+	mockUserStore := app.store.Users.(*store.MockUserStore)
+	mockUserStore.On("GetByEmail", mock.Anything, inactiveUser.Email).Return(inactiveUser, nil)
+
+	body, err := json.Marshal(CreateUserTokenPayload{Email: inactiveUser.Email, Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", "/v1/authentication/token", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusForbidden, rr.Code)
+	checkResponseBody(t, map[string]any{"error": "user account is not activated"}, rr.Body.Bytes())
+
+	mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+	mockRefreshTokenStore.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestTwoFactorAuthentication(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		auth: &authConfig{
+			tokenCfg: tokenConfig{
+				exp:    time.Hour,
+				mfaExp: time.Minute * 5,
+			},
+		},
+	}
+
+	t.Run("should return a challenge instead of tokens when two-factor authentication is enabled", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+
+		user := &store.User{
+			ID:         5,
+			Username:   "MFAUser",
+			Email:      "mfa@mail.com",
+			Active:     true,
+			MFAEnabled: true,
+		}
+		if err := user.Password.Set("password"); err != nil {
+			t.Fatal(err)
+		}
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+
+		body, err := json.Marshal(CreateUserTokenPayload{Email: user.Email, Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/v1/authentication/token", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		var got struct {
+			Data MFAChallengeResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, got.Data.MFARequired)
+		assert.NotEmpty(t, got.Data.MFAToken)
+
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.AssertNumberOfCalls(t, "Create", 0)
+	})
+
+	t.Run("should require enrollment when the role requires two-factor authentication", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.ExpectedCalls = nil
+		mockUserStore.On("GetByID", mock.Anything, int64(1)).Return(&store.User{
+			ID:     1,
+			Active: true,
+			Role:   store.Role{ID: 2, Name: "moderator", Level: 50, MFARequired: true},
+		}, nil)
+
+		req, err := http.NewRequest("GET", "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		checkResponseBody(t, map[string]any{"error": errMFAEnrollmentRequired.Error()}, rr.Body.Bytes())
+	})
+}
+
+func TestLoginThrottle(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		loginThrottle: &loginThrottleConfig{
+			enabled:         true,
+			window:          15 * time.Minute,
+			freeAttempts:    3,
+			baseDelay:       time.Second,
+			maxDelay:        30 * time.Second,
+			emailLockout:    10,
+			ipLockout:       50,
+			lockoutDuration: 15 * time.Minute,
+		},
+	}
+
+	t.Run("should reject login for a locked out email", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+
+		lockedUntil := time.Now().Add(10 * time.Minute)
+		mockLoginAttemptStore := app.store.LoginAttempts.(*store.MockLoginAttemptStore)
+		mockLoginAttemptStore.On("Get", mock.Anything, store.LoginScopeEmail, "locked@mail.com").Return(&store.LoginAttempt{
+			Scope:        store.LoginScopeEmail,
+			Key:          "locked@mail.com",
+			Failures:     10,
+			LastFailedAt: time.Now(),
+			LockedUntil:  &lockedUntil,
+		}, nil)
+		mockLoginAttemptStore.On("Get", mock.Anything, store.LoginScopeIP, mock.Anything).Return(nil, store.ErrNotFound)
+
+		body, err := json.Marshal(CreateUserTokenPayload{Email: "Locked@mail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/v1/authentication/token", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "600", rr.Header().Get("Retry-After"))
+
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should lock out the email when failures reach the threshold", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("GetByEmail", mock.Anything, "unknown@mail.com").Return(nil, store.ErrNotFound)
+
+		mockLoginAttemptStore := app.store.LoginAttempts.(*store.MockLoginAttemptStore)
+		mockLoginAttemptStore.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, store.ErrNotFound)
+		mockLoginAttemptStore.On("RecordFailure", mock.Anything, store.LoginScopeEmail, "unknown@mail.com", cfg.loginThrottle.window).
+			Return(&store.LoginAttempt{Scope: store.LoginScopeEmail, Key: "unknown@mail.com", Failures: 10, LastFailedAt: time.Now()}, nil)
+		mockLoginAttemptStore.On("RecordFailure", mock.Anything, store.LoginScopeIP, mock.Anything, cfg.loginThrottle.window).
+			Return(&store.LoginAttempt{Scope: store.LoginScopeIP, Failures: 10, LastFailedAt: time.Now()}, nil)
+		mockLoginAttemptStore.On("Lock", mock.Anything, store.LoginScopeEmail, "unknown@mail.com", mock.Anything).Return(nil)
+
+		body, err := json.Marshal(CreateUserTokenPayload{Email: "unknown@mail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/v1/authentication/token", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+		mockLoginAttemptStore.AssertCalled(t, "Lock", mock.Anything, store.LoginScopeEmail, "unknown@mail.com", mock.Anything)
+		mockLoginAttemptStore.AssertNotCalled(t, "Lock", mock.Anything, store.LoginScopeIP, mock.Anything, mock.Anything)
+	})
+}
+
+func TestLoginThrottleRetryAfter(t *testing.T) {
+	cfg := &loginThrottleConfig{
+		window:       15 * time.Minute,
+		freeAttempts: 3,
+		baseDelay:    time.Second,
+		maxDelay:     30 * time.Second,
+	}
+	now := time.Now()
+	lockedUntil := now.Add(time.Minute)
+
+	tests := []struct {
+		name     string
+		attempt  *store.LoginAttempt
+		expected time.Duration
+	}{
+		{name: "no failures", attempt: nil, expected: 0},
+		{name: "free attempts", attempt: &store.LoginAttempt{Failures: 3, LastFailedAt: now}, expected: 0},
+		{name: "first delay", attempt: &store.LoginAttempt{Failures: 4, LastFailedAt: now}, expected: time.Second},
+		{name: "progressive delay", attempt: &store.LoginAttempt{Failures: 6, LastFailedAt: now}, expected: 4 * time.Second},
+		{name: "delay is capped", attempt: &store.LoginAttempt{Failures: 100, LastFailedAt: now}, expected: 30 * time.Second},
+		{name: "delay has passed", attempt: &store.LoginAttempt{Failures: 4, LastFailedAt: now.Add(-2 * time.Second)}, expected: 0},
+		{name: "failures outside the window", attempt: &store.LoginAttempt{Failures: 9, LastFailedAt: now.Add(-time.Hour)}, expected: 0},
+		{name: "locked out", attempt: &store.LoginAttempt{Failures: 10, LastFailedAt: now, LockedUntil: &lockedUntil}, expected: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, cfg.retryAfter(tt.attempt, now))
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	app := &application{config: config{trustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{name: "direct request", remoteAddr: "203.0.113.7:4321", expected: "203.0.113.7"},
+		{
+			name:       "forged headers of a direct request",
+			remoteAddr: "203.0.113.7:4321",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.1"},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "request through trusted proxies",
+			remoteAddr: "10.0.0.1:4321",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "address forged by the client before the proxy",
+			remoteAddr: "10.0.0.1:4321",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.9, 198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "real IP header of a trusted proxy",
+			remoteAddr: "10.0.0.1:4321",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{name: "trusted proxy without forwarding headers", remoteAddr: "10.0.0.1:4321", expected: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/v1/authentication/token", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			var ip string
+			app.realIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = clientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.expected, ip)
+		})
+	}
+}
+
+func TestPersonalTokens(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+
+	feedToken := personalTokenPrefix + "feed"
+	postsToken := personalTokenPrefix + "posts"
+	mockPersonalTokenStore := app.store.PersonalTokens.(*store.MockPersonalTokenStore)
+	mockPersonalTokenStore.On("Use", mock.Anything, hashToken(feedToken)).Return(
+		&store.PersonalToken{ID: 1, UserID: 2, Scopes: []string{store.ScopeFeedRead}}, nil)
+	mockPersonalTokenStore.On("Use", mock.Anything, hashToken(postsToken)).Return(
+		&store.PersonalToken{ID: 2, UserID: 2, Scopes: []string{store.ScopePostsWrite}}, nil)
+	mockPersonalTokenStore.On("Use", mock.Anything, mock.Anything).Return(nil, store.ErrNotFound)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should reject unknown token",
+			method:         "GET",
+			path:           "/v1/users/feed",
+			token:          personalTokenPrefix + "unknown",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   map[string]any{"error": "invalid token"},
+		},
+		{
+			name:           "should reject token without the required scope",
+			method:         "GET",
+			path:           "/v1/users/feed",
+			token:          postsToken,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   map[string]any{"error": "token does not have the feed:read scope"},
+		},
+		{
+			name:           "should not read a post without the required scope",
+			method:         "GET",
+			path:           "/v1/posts/10",
+			token:          postsToken,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   map[string]any{"error": "token does not have the feed:read scope"},
+		},
+		{
+			name:           "should not read the comments of a post without the required scope",
+			method:         "GET",
+			path:           "/v1/posts/10/comments",
+			token:          postsToken,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   map[string]any{"error": "token does not have the feed:read scope"},
+		},
+		{
+			name:           "should reject personal tokens on endpoints without a scope",
+			method:         "GET",
+			path:           "/v1/users/2",
+			token:          feedToken,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   map[string]any{"error": errPersonalTokenNotAllowed.Error()},
+		},
+		{
+			name:           "should not manage personal tokens with a personal token",
+			method:         "GET",
+			path:           "/v1/users/me/tokens",
+			token:          feedToken,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   map[string]any{"error": errPersonalTokenNotAllowed.Error()},
+		},
+		{
+			name:           "should not log out with a personal token",
+			method:         "POST",
+			path:           "/v1/authentication/logout",
+			token:          feedToken,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   map[string]any{"error": errPersonalTokenNotAllowed.Error()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tt.expectedStatus, rr.Code)
+			checkResponseBody(t, tt.expectedBody, rr.Body.Bytes())
+		})
+	}
+}
+
+func TestAccessTokenIssuedAt(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		auth: &authConfig{
+			tokenCfg: tokenConfig{
+				audience: "gopher.social",
+				issuer:   "gopher.social",
+				exp:      time.Hour,
+			},
+		},
+	}
+	app := newTestApp(t, cfg)
+	app.authenticator = auth.NewJWTAuthenticator("secret", cfg.auth.tokenCfg.audience, cfg.auth.tokenCfg.issuer)
+
+	before := time.Now().Truncate(time.Microsecond)
+	tokenString, err := app.generateAccessToken(&store.User{ID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.authenticator.ValidateToken(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuedAt, err := issuedAtFromClaims(token.Claims.(jwt.MapClaims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a token issued before a revocation in the same second must be told apart from one issued after it
+	if issuedAt.Before(before) || issuedAt.After(time.Now()) {
+		t.Fatalf("expected the issue time to the microsecond, got %v, issued after %v", issuedAt, before)
+	}
+}
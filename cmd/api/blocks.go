@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var errSelfRelationship = errors.New("users cannot block or mute themselves")
+
+// blockUserHandler godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user by ID. The users stop following each other, cannot follow each other again
+//	@Description	or comment on each other's posts, and do not see each other's content
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRelationship(w, r, func(ctx context.Context, userID, otherID int64) error {
+		if err := app.store.Blocks.Block(ctx, userID, otherID); err != nil {
+			return err
+		}
+		// blocking removes the follows between the users
+		app.invalidateTimelines(ctx, userID, otherID)
+		return nil
+	})
+}
+
+// unblockUserHandler godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Unblocks a user by ID
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRelationship(w, r, app.store.Blocks.Unblock)
+}
+
+// muteUserHandler godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Mutes a user by ID, hiding the posts of the user from the feed without them knowing
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRelationship(w, r, app.store.Mutes.Mute)
+}
+
+// unmuteUserHandler godoc
+//
+//	@Summary		Unmutes a user
+//	@Description	Unmutes a user by ID
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRelationship(w, r, app.store.Mutes.Unmute)
+}
+
+func (app *application) changeUserRelationship(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userID, otherID int64) error) {
+	otherID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	user := app.getUserFromContext(r)
+	if otherID == user.ID {
+		app.badRequestError(w, r, errSelfRelationship)
+		return
+	}
+	if _, err := app.getUser(r.Context(), otherID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := change(r.Context(), user.ID, otherID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.noContentResponse(w)
+}
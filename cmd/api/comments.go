@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/go-chi/chi/v5"
 )
 
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
 type CommentPayload struct {
 	Content string `json:"content" validate:"required,max=1000"`
+	// ParentID is the comment being replied to
+	ParentID *int64 `json:"parent_id"`
 }
 
+var errParentComment = errors.New("parent comment not found or deleted")
+
 func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
 	user := app.getUserFromContext(r)
 	post := app.getPostFromContext(r)
@@ -27,15 +40,278 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		User: &store.User{
 			ID: user.ID,
 		},
-		Content: commentPayload.Content,
+		Content:  commentPayload.Content,
+		ParentID: commentPayload.ParentID,
+	}
+	if comment.ParentID != nil {
+		parent, err := app.store.Comments.GetByID(r.Context(), *comment.ParentID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestError(w, r, errParentComment)
+			return
+		case err != nil:
+			app.internalServerError(w, r, err)
+			return
+		}
+		if parent.PostID != post.ID || parent.DeletedAt != nil {
+			app.badRequestError(w, r, errParentComment)
+			return
+		}
+		if parent.Depth >= app.config.comments.maxDepth {
+			app.badRequestError(w, r, fmt.Errorf("replies cannot nest more than %d levels deep", app.config.comments.maxDepth))
+			return
+		}
+		comment.Depth = parent.Depth + 1
 	}
 
 	if err := app.store.Comments.Create(r.Context(), comment); err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrBlocked):
+			app.resourceForbiddenError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestError(w, r, errParentComment)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
+	app.publishComment(r.Context(), post, comment)
 
 	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+// getCommentsHandler godoc
+//
+//	@Summary		Fetches the comments of a post
+//	@Description	Fetches a page of the top-level comments of a post with the number of their replies, sorted oldest
+//	@Description	first by default, newest first, or top, with the most replies first. Pass parent_id to page through
+//	@Description	the replies to a comment instead, or view=tree to get the page of top-level comments with all their
+//	@Description	replies nested under them. Deleted comments with replies are kept as tombstones
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			view		query		string	false	"page (default) or tree"
+//	@Param			parent_id	query		int		false	"Comment whose replies are fetched"
+//	@Param			sort		query		string	false	"oldest (default), newest or top"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor of the page"
+//	@Success		200			{object}	[]store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserFromContext(r)
+	post := app.getPostFromContext(r)
+	query := r.URL.Query()
+	view := query.Get("view")
+	switch view {
+	case "", "page", "tree":
+	default:
+		app.badRequestError(w, r, errors.New("view must be page or tree"))
+		return
+	}
+
+	paginatedQuery, err := store.ParsePaginatedCommentQuery(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(paginatedQuery); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if view == "tree" {
+		app.getCommentTree(w, r, post.ID, user.ID, paginatedQuery)
+		return
+	}
+	var parentID *int64
+	if param := query.Get("parent_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		parentID = &id
+	}
+
+	comments, next, err := app.store.Comments.GetPage(r.Context(), post.ID, parentID, user.ID, paginatedQuery)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	if err := app.paginatedJSONResponse(w, http.StatusOK, comments, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getCommentTree responds with a page of the top-level comments of the post with all their replies nested
+// under them.
+func (app *application) getCommentTree(w http.ResponseWriter, r *http.Request, postID, viewerID int64, paginatedQuery *store.PaginatedCommentQuery) {
+	comments, next, err := app.store.Comments.GetPage(r.Context(), postID, nil, viewerID, paginatedQuery)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(comments) > 0 {
+		ids := make([]int64, len(comments))
+		for i, comment := range comments {
+			ids[i] = comment.ID
+		}
+		replies, err := app.store.Comments.GetReplies(r.Context(), postID, ids, viewerID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		comments = append(comments, replies...)
+	}
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	if err := app.paginatedJSONResponse(w, http.StatusOK, store.CommentTree(comments), nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type updateCommentRequest struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// updateCommentHandler godoc
+//
+//	@Summary		Edits a comment
+//	@Description	Replaces the content of a comment. Allowed for the author and moderators; the previous content
+//	@Description	is kept in the edit history of the comment
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		updateCommentRequest	true	"Comment"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserFromContext(r)
+	comment := app.getCommentFromContext(r)
+	var payload updateCommentRequest
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	comment.Content = payload.Content
+	if err := app.store.Comments.Update(r.Context(), comment, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteCommentHandler godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment. Allowed for the author, the author of the post and admins. A comment with
+//	@Description	replies is kept as a tombstone
+//	@Tags			posts
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//	@Success		204			{string}	string	"Comment deleted"
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := app.getCommentFromContext(r)
+	if err := app.store.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.noContentResponse(w)
+}
+
+// getCommentEditsHandler godoc
+//
+//	@Summary		Fetches the edit history of a comment
+//	@Description	Fetches the previous contents of a comment, newest first. Allowed for moderators
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		200			{object}	[]store.CommentEdit
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/edits [get]
+func (app *application) getCommentEditsHandler(w http.ResponseWriter, r *http.Request) {
+	comment := app.getCommentFromContext(r)
+	edits, err := app.store.Comments.GetEdits(r.Context(), comment.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, edits); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// commentsContextMiddleware loads the comment of the post in the context. Tombstones are not found.
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+		comment, err := app.store.Comments.GetByID(r.Context(), commentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.resourceNotFound(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		if comment.PostID != app.getPostFromContext(r).ID || comment.DeletedAt != nil {
+			app.resourceNotFound(w, r, store.ErrNotFound)
+			return
+		}
+		ctx := context.WithValue(r.Context(), commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (app *application) getCommentFromContext(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtx).(*store.Comment)
+	return comment
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCommentThreads(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		comments: &commentsConfig{
+			maxDepth: 2,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parentOf := func(id int64) *int64 { return &id }
+	deletedAt := time.Now()
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetByID", mock.Anything, int64(10)).Return(&store.Post{ID: 10, UserID: 2}, nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("GetByID", mock.Anything, int64(1)).Return(&store.Comment{ID: 1, PostID: 10}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, int64(2)).Return(&store.Comment{ID: 2, PostID: 10, ParentID: parentOf(1), Depth: 1}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, int64(3)).Return(&store.Comment{ID: 3, PostID: 10, ParentID: parentOf(2), Depth: 2}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, int64(4)).Return(&store.Comment{ID: 4, PostID: 20}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, int64(5)).Return(&store.Comment{ID: 5, PostID: 10, DeletedAt: &deletedAt}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, mock.Anything).Return(nil, store.ErrNotFound)
+	mockCommentStore.On("Create", mock.Anything, mock.MatchedBy(func(c *store.Comment) bool {
+		return c.ParentID != nil && *c.ParentID == 2 && c.Depth == 2
+	})).Return(nil)
+	mockCommentStore.On("GetPage", mock.Anything, int64(10), (*int64)(nil), int64(1), &store.PaginatedCommentQuery{Limit: 2}).Return(
+		[]*store.Comment{{ID: 1, PostID: 10, DeletedAt: &deletedAt, ReplyCount: 1}, {ID: 6, PostID: 10, Content: "second"}},
+		&store.Cursor{CreatedAt: deletedAt, ID: 6}, nil)
+	mockCommentStore.On("GetReplies", mock.Anything, int64(10), []int64{1, 6}, int64(1)).Return([]*store.Comment{
+		{ID: 2, PostID: 10, ParentID: parentOf(1), Depth: 1, Content: "reply"},
+	}, nil)
+	mockCommentStore.On("GetPage", mock.Anything, int64(10), (*int64)(nil), int64(1), &store.PaginatedCommentQuery{Limit: 1}).Return(
+		[]*store.Comment{{ID: 1, PostID: 10, ReplyCount: 1}}, &store.Cursor{CreatedAt: deletedAt, ID: 1}, nil)
+	mockCommentStore.On("GetPage", mock.Anything, int64(10), parentOf(1), int64(1), &store.PaginatedCommentQuery{Limit: 20}).Return(
+		[]*store.Comment{{ID: 2, PostID: 10, ParentID: parentOf(1), Depth: 1}}, nil, nil)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "should reply to a comment",
+			body:           `{"content": "reply", "parent_id": 2}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "should not reply deeper than the configured depth",
+			body:           `{"content": "reply", "parent_id": 3}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should not reply to a comment of another post",
+			body:           `{"content": "reply", "parent_id": 4}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should not reply to a deleted comment",
+			body:           `{"content": "reply", "parent_id": 5}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should not reply to a missing comment",
+			body:           `{"content": "reply", "parent_id": 99}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/v1/posts/10/comments", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+		})
+	}
+
+	get := func(t *testing.T, path string, response any) {
+		t.Helper()
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if err := json.Unmarshal(rr.Body.Bytes(), response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should return a page of the comments as a tree", func(t *testing.T) {
+		var response struct {
+			Data       []*store.Comment `json:"data"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		get(t, "/v1/posts/10/comments?view=tree&limit=2", &response)
+		if assert.Len(t, response.Data, 2) {
+			assert.Equal(t, int64(1), response.Data[0].ID)
+			assert.NotNil(t, response.Data[0].DeletedAt)
+			assert.Equal(t, 1, response.Data[0].ReplyCount)
+			if assert.Len(t, response.Data[0].Replies, 1) {
+				assert.Equal(t, "reply", response.Data[0].Replies[0].Content)
+			}
+			assert.Equal(t, int64(6), response.Data[1].ID)
+			assert.Empty(t, response.Data[1].Replies)
+		}
+		assert.NotEmpty(t, response.NextCursor)
+	})
+
+	t.Run("should page through the top-level comments", func(t *testing.T) {
+		var response struct {
+			Data       []*store.Comment `json:"data"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		get(t, "/v1/posts/10/comments?limit=1", &response)
+		if assert.Len(t, response.Data, 1) {
+			assert.Equal(t, 1, response.Data[0].ReplyCount)
+		}
+		assert.NotEmpty(t, response.NextCursor)
+	})
+
+	t.Run("should page through the replies to a comment", func(t *testing.T) {
+		var response struct {
+			Data       []*store.Comment `json:"data"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		get(t, "/v1/posts/10/comments?parent_id=1", &response)
+		if assert.Len(t, response.Data, 1) {
+			assert.Equal(t, int64(2), response.Data[0].ID)
+		}
+		assert.Empty(t, response.NextCursor)
+	})
+}
+
+func TestCommentModeration(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	moderatorToken, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postOwnerToken := personalTokenPrefix + "user2"
+	commenterToken := personalTokenPrefix + "user3"
+	mockPersonalTokenStore := app.store.PersonalTokens.(*store.MockPersonalTokenStore)
+	mockPersonalTokenStore.On("Use", mock.Anything, hashToken(postOwnerToken)).Return(
+		&store.PersonalToken{ID: 1, UserID: 2, Scopes: []string{store.ScopeCommentsWrite}}, nil)
+	mockPersonalTokenStore.On("Use", mock.Anything, hashToken(commenterToken)).Return(
+		&store.PersonalToken{ID: 2, UserID: 3, Scopes: []string{store.ScopeCommentsWrite}}, nil)
+
+	deletedAt := time.Now()
+	mockRoleStore := app.store.Roles.(*store.MockRoleStore)
+	mockRoleStore.On("GetByName", mock.Anything, "moderator").Return(&store.Role{Name: "moderator", Level: 50}, nil)
+	mockRoleStore.On("GetByName", mock.Anything, "admin").Return(&store.Role{Name: "admin", Level: 100}, nil)
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetByID", mock.Anything, int64(10)).Return(&store.Post{ID: 10, UserID: 2}, nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("GetByID", mock.Anything, int64(1)).Return(&store.Comment{ID: 1, PostID: 10, User: &store.User{ID: 3}}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, int64(2)).Return(&store.Comment{ID: 2, PostID: 10, User: &store.User{ID: 1}, Version: 3}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, int64(3)).Return(&store.Comment{ID: 3, PostID: 20, User: &store.User{ID: 3}}, nil)
+	mockCommentStore.On("GetByID", mock.Anything, int64(4)).Return(&store.Comment{ID: 4, PostID: 10, DeletedAt: &deletedAt}, nil)
+	mockCommentStore.On("Update", mock.Anything, mock.MatchedBy(func(c *store.Comment) bool { return c.ID == 1 }), int64(1)).Return(nil)
+	// edited by someone else since it was read
+	mockCommentStore.On("Update", mock.Anything, mock.MatchedBy(func(c *store.Comment) bool { return c.ID == 2 }), int64(1)).Return(store.ErrNotFound)
+	mockCommentStore.On("Delete", mock.Anything, int64(1)).Return(nil)
+	mockCommentStore.On("GetEdits", mock.Anything, int64(1)).Return([]*store.CommentEdit{{ID: 1, CommentID: 1, Content: "before", EditedBy: 3}}, nil)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "should let a moderator edit a comment",
+			method:         "PATCH",
+			path:           "/v1/posts/10/comments/1",
+			token:          moderatorToken,
+			body:           `{"content": "edited"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should not let the author of the post edit a comment",
+			method:         "PATCH",
+			path:           "/v1/posts/10/comments/1",
+			token:          postOwnerToken,
+			body:           `{"content": "edited"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should not save an edit of a comment changed meanwhile",
+			method:         "PATCH",
+			path:           "/v1/posts/10/comments/2",
+			token:          moderatorToken,
+			body:           `{"content": "edited"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should not edit a comment of another post",
+			method:         "PATCH",
+			path:           "/v1/posts/10/comments/3",
+			token:          moderatorToken,
+			body:           `{"content": "edited"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should not edit a deleted comment",
+			method:         "PATCH",
+			path:           "/v1/posts/10/comments/4",
+			token:          moderatorToken,
+			body:           `{"content": "edited"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should not let a moderator delete a comment of another user",
+			method:         "DELETE",
+			path:           "/v1/posts/10/comments/1",
+			token:          moderatorToken,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should not let a personal token delete a comment of another user",
+			method:         "DELETE",
+			path:           "/v1/posts/10/comments/2",
+			token:          commenterToken,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should let the author of the post delete a comment",
+			method:         "DELETE",
+			path:           "/v1/posts/10/comments/1",
+			token:          postOwnerToken,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "should show the edit history to moderators",
+			method:         "GET",
+			path:           "/v1/posts/10/comments/1/edits",
+			token:          moderatorToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should not show the edit history to a personal token",
+			method:         "GET",
+			path:           "/v1/posts/10/comments/1/edits",
+			token:          commenterToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestGetPostComments(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetByID", mock.Anything, int64(10)).Return(&store.Post{ID: 10, UserID: 2}, nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockReactionStore := app.store.Reactions.(*store.MockReactionStore)
+	mockReactionStore.On("GetSummary", mock.Anything, mock.Anything, mock.Anything).Return(&store.ReactionSummary{}, nil)
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("GetPage", mock.Anything, int64(10), (*int64)(nil), int64(1), &store.PaginatedCommentQuery{Limit: firstCommentsPageLimit}).Return(
+		[]*store.Comment{{ID: 1, PostID: 10}}, &store.Cursor{CreatedAt: createdAt, ID: 1}, nil)
+	mockCommentStore.On("GetPage", mock.Anything, int64(10), (*int64)(nil), int64(1), &store.PaginatedCommentQuery{Limit: 2, Sort: store.CommentSortTop}).Return(
+		[]*store.Comment{{ID: 3, PostID: 10, ReplyCount: 5}, {ID: 2, PostID: 10, ReplyCount: 1}}, nil, nil)
+	mockCommentStore.On("CountByPostID", mock.Anything, int64(10), int64(1)).Return(42, nil)
+
+	get := func(t *testing.T, path string, expectedStatus int, response any) {
+		t.Helper()
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, expectedStatus, rr.Code)
+		if response != nil {
+			if err := json.Unmarshal(rr.Body.Bytes(), response); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("should return the comment count and the first page of comments with the post", func(t *testing.T) {
+		var response struct {
+			Data store.Post `json:"data"`
+		}
+		get(t, "/v1/posts/10", http.StatusOK, &response)
+		assert.Equal(t, 42, response.Data.CommentsCount)
+		assert.Len(t, response.Data.Comments, 1)
+		assert.Equal(t, (&store.Cursor{CreatedAt: createdAt, ID: 1}).Encode(), response.Data.CommentsNextCursor)
+	})
+
+	t.Run("should sort the comments by replies", func(t *testing.T) {
+		var response struct {
+			Data []*store.Comment `json:"data"`
+		}
+		get(t, "/v1/posts/10/comments?sort=top&limit=2", http.StatusOK, &response)
+		if assert.Len(t, response.Data, 2) {
+			assert.Equal(t, int64(3), response.Data[0].ID)
+		}
+	})
+
+	t.Run("should reject an unknown sort", func(t *testing.T) {
+		get(t, "/v1/posts/10/comments?sort=best", http.StatusBadRequest, nil)
+	})
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -76,3 +80,14 @@ func (app *application) rateLimitExceededError(w http.ResponseWriter, r *http.Re
 	w.Header().Set("Retry-After", retryAfter)
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *application) tooManyLoginAttemptsError(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("too many login attempts",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"retryAfter", retryAfter,
+	)
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("too many failed login attempts, retry after %ds", seconds))
+}
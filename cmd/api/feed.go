@@ -2,27 +2,36 @@ package main
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/NikolayProkopchuk/social/internal/ranking"
 	"github.com/NikolayProkopchuk/social/internal/store"
 )
 
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches a page of the user feed. Pass next_cursor of the response as cursor to get
+//	@Description	the next page; offset is kept for older clients and cannot be combined with cursor
+//	@Description	With mode=ranked the recent posts of the followed users and of the users they follow are
+//	@Description	sorted by a score of recency, comments, reactions and interactions with the author, paginated with offset
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
-//	@Param			limit	query		int		false	"Limit"
-//	@Param			offset	query		int		false	"Offset"
-//	@Param			sort	query		string	false	"Sort"
-//	@Param			tags	query		string	false	"Tags"
-//	@Param			search	query		string	false	"Search"
-//	@Success		200		{object}	[]store.PostWithMetadata
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			since		query		string	false	"Since"
+//	@Param			until		query		string	false	"Until"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Param			cursor		query		string	false	"Cursor of the page"
+//	@Param			sort		query		string	false	"Sort direction, asc or desc"
+//	@Param			sort_by		query		string	false	"Sort key: created_at (oldest first by default), comments or activity (most first by default)"
+//	@Param			tags		query		string	false	"Tags"
+//	@Param			search		query		string	false	"Search"
+//	@Param			include_own	query		bool	false	"Include the posts of the current user"
+//	@Param			mode		query		string	false	"chronological (default) or ranked"
+//	@Success		200			{object}	[]store.PostWithMetadata
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
@@ -30,18 +39,63 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	paginatedFeedQuery, err := store.ParsePaginatedFeedQuery(r)
 	if err != nil {
 		app.badRequestError(w, r, err)
+		return
 	}
 	err = Validator.Struct(paginatedFeedQuery)
 	if err != nil {
 		app.badRequestError(w, r, err)
 		return
 	}
-	feed, err := app.store.Posts.GetUserFeed(r.Context(), user, paginatedFeedQuery)
+	if paginatedFeedQuery.Mode == store.FeedModeRanked {
+		app.rankedFeedResponse(w, r, user, paginatedFeedQuery)
+		return
+	}
+	paginatedFeedQuery.Timeline = app.feedTimeline(r.Context(), user.ID, paginatedFeedQuery)
+	feed, next, err := app.store.Posts.GetUserFeed(r.Context(), user, paginatedFeedQuery)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	if err = app.paginatedJSONResponse(w, http.StatusOK, feed, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// rankedFeedResponse ranks the newest posts within the ranking window and writes the requested page.
+func (app *application) rankedFeedResponse(w http.ResponseWriter, r *http.Request, user *store.User, paginatedFeedQuery *store.PaginatedFeedQuery) {
+	now := time.Now()
+	candidatesQuery := *paginatedFeedQuery
+	if windowStart := now.Add(-app.config.ranking.window); candidatesQuery.Since.Before(windowStart) {
+		candidatesQuery.Since = windowStart
+	}
+	candidates, err := app.store.Posts.GetFeedCandidates(r.Context(), user, &candidatesQuery, app.config.ranking.candidates)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	if err = app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	ranking.Rank(candidates, feedSignals, app.config.ranking.weights, now)
+
+	start := min(paginatedFeedQuery.Offset, len(candidates))
+	end := min(start+paginatedFeedQuery.Limit, len(candidates))
+	feed := make([]*store.PostWithMetadata, 0, end-start)
+	for _, candidate := range candidates[start:end] {
+		feed = append(feed, candidate.PostWithMetadata)
+	}
+	if err := app.paginatedJSONResponse(w, http.StatusOK, feed, ""); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+func feedSignals(candidate *store.FeedCandidate) ranking.Signals {
+	return ranking.Signals{
+		CreatedAt:    candidate.CreatedAt,
+		Comments:     candidate.CommentsCount,
+		Likes:        candidate.Total(),
+		Affinity:     candidate.Affinity,
+		SecondDegree: candidate.SecondDegree,
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/ranking"
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetUserFeed(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cursor := &store.Cursor{CreatedAt: createdAt, ID: 7}
+	isFirstPage := mock.MatchedBy(func(q *store.PaginatedFeedQuery) bool { return q.Cursor == nil && !q.IncludeOwn })
+	withOwnPosts := mock.MatchedBy(func(q *store.PaginatedFeedQuery) bool { return q.IncludeOwn })
+	isNextPage := mock.MatchedBy(func(q *store.PaginatedFeedQuery) bool { return q.Cursor != nil && *q.Cursor == *cursor })
+	myReaction := store.ReactionLike
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetUserFeed", mock.Anything, mock.Anything, isFirstPage).Return(
+		[]*store.PostWithMetadata{{
+			ID:             7,
+			Title:          "First",
+			UserID:         2,
+			CreatedAt:      createdAt,
+			LastActivityAt: createdAt,
+			Author:         &store.PostAuthor{ID: 2, Username: "TestUser", AvatarURL: "https://example.com/avatar.png"},
+			ReactionSummary: store.ReactionSummary{
+				Reactions:  map[string]int{store.ReactionLike: 3, store.ReactionWow: 1},
+				MyReaction: &myReaction,
+			},
+		}}, cursor, nil)
+	mockPostStore.On("GetUserFeed", mock.Anything, mock.Anything, withOwnPosts).Return(
+		[]*store.PostWithMetadata{{ID: 8, UserID: 1, Author: &store.PostAuthor{ID: 1, Username: "TestModeratorUser"}}}, (*store.Cursor)(nil), nil)
+	mockPostStore.On("GetUserFeed", mock.Anything, mock.Anything, isNextPage).Return(
+		[]*store.PostWithMetadata{}, (*store.Cursor)(nil), nil)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should return the cursor of the next page",
+			path:           "/v1/users/feed?limit=1&sort=desc",
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]any{
+				"data": []any{
+					map[string]any{
+						"id":             float64(7),
+						"title":          "First",
+						"content":        "",
+						"userId":         float64(2),
+						"tags":           nil,
+						"createdAt":      "2025-01-02T03:04:05Z",
+						"commentsCount":  float64(0),
+						"lastActivityAt": "2025-01-02T03:04:05Z",
+						"author": map[string]any{
+							"id":         float64(2),
+							"username":   "TestUser",
+							"avatar_url": "https://example.com/avatar.png",
+						},
+						"reactions":   map[string]any{"like": float64(3), "wow": float64(1)},
+						"my_reaction": "like",
+					},
+				},
+				"next_cursor": cursor.Encode(),
+			},
+		},
+		{
+			name:           "should continue from the cursor",
+			path:           "/v1/users/feed?limit=1&sort=desc&cursor=" + cursor.Encode(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]any{"data": []any{}},
+		},
+		{
+			name:           "should keep the offset mode",
+			path:           "/v1/users/feed?limit=1&offset=10",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should reject a cursor with an offset",
+			path:           "/v1/users/feed?offset=10&cursor=" + cursor.Encode(),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should include own posts when asked to",
+			path:           "/v1/users/feed?include_own=true",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should reject an invalid include_own",
+			path:           "/v1/users/feed?include_own=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject an unknown sort key",
+			path:           "/v1/users/feed?sort_by=title",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject an unknown sort direction",
+			path:           "/v1/users/feed?sort=random()",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject a cursor when sorting by comments",
+			path:           "/v1/users/feed?sort_by=comments&cursor=" + cursor.Encode(),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject until before since",
+			path:           "/v1/users/feed?since=2025-01-02T00:00:00Z&until=2025-01-01T00:00:00Z",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject an invalid cursor",
+			path:           "/v1/users/feed?cursor=invalid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]any{"error": store.ErrInvalidCursor.Error()},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedBody != nil {
+				checkResponseBody(t, tc.expectedBody, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestGetRankedUserFeed(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		ranking: &rankingConfig{
+			weights:    ranking.Weights{Recency: 1, Comments: 0.5, Likes: 0.3, Affinity: 0.4, SecondDegree: 0.5, HalfLife: 12 * time.Hour},
+			window:     72 * time.Hour,
+			candidates: 100,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	candidate := func(id int64, age time.Duration, comments int) *store.FeedCandidate {
+		return &store.FeedCandidate{PostWithMetadata: &store.PostWithMetadata{ID: id, CreatedAt: now.Add(-age), CommentsCount: comments}}
+	}
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetFeedCandidates", mock.Anything, mock.Anything, mock.MatchedBy(func(q *store.PaginatedFeedQuery) bool {
+		// the candidates are limited to the ranking window
+		return !q.Since.Before(now.Add(-72*time.Hour)) && q.Since.Before(now)
+	}), 100).Return([]*store.FeedCandidate{
+		candidate(1, time.Hour, 0),
+		candidate(2, 2*time.Hour, 10),
+		candidate(3, 48*time.Hour, 0),
+	}, nil)
+
+	ids := func(body []byte) []int64 {
+		var response struct {
+			Data []struct {
+				ID int64 `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, post := range response.Data {
+			ids = append(ids, post.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedIDs    []int64
+	}{
+		{
+			name:           "should rank the discussed post first",
+			path:           "/v1/users/feed?mode=ranked",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []int64{2, 1, 3},
+		},
+		{
+			name:           "should page the ranked posts with offset",
+			path:           "/v1/users/feed?mode=ranked&limit=1&offset=1",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []int64{1},
+		},
+		{
+			name:           "should return an empty page past the ranked posts",
+			path:           "/v1/users/feed?mode=ranked&offset=10",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []int64{},
+		},
+		{
+			name:           "should reject a sort with the ranked mode",
+			path:           "/v1/users/feed?mode=ranked&sort=desc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject an unknown mode",
+			path:           "/v1/users/feed?mode=popular",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedIDs != nil {
+				assert.Equal(t, tc.expectedIDs, ids(rr.Body.Bytes()))
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// getFollowRequestsHandler godoc
+//
+//	@Summary		Fetches follow requests
+//	@Description	Fetches a page of the pending requests to follow the current user, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the page"
+//	@Success		200		{object}	[]store.FollowUser
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	paginatedQuery, err := store.ParsePaginatedCursorQuery(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(paginatedQuery); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := app.getUserFromContext(r)
+	requests, next, err := app.store.Followers.GetFollowRequests(r.Context(), user.ID, paginatedQuery)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	if err := app.paginatedJSONResponse(w, http.StatusOK, requests, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// approveFollowRequestHandler godoc
+//
+//	@Summary		Approves a follow request
+//	@Description	Approves the pending request of a user to follow the current user
+//	@Tags			users
+//	@Param			userID	path		int		true	"ID of the requesting user"
+//	@Success		204		{string}	string	"Follow request approved"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID} [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, func(ctx context.Context, userID, followerID int64) error {
+		if err := app.store.Followers.AcceptFollowRequest(ctx, userID, followerID); err != nil {
+			return err
+		}
+		app.invalidateTimelines(ctx, followerID)
+		return nil
+	})
+}
+
+// rejectFollowRequestHandler godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Rejects the pending request of a user to follow the current user
+//	@Tags			users
+//	@Param			userID	path		int		true	"ID of the requesting user"
+//	@Success		204		{string}	string	"Follow request rejected"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{userID} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.Followers.RejectFollowRequest)
+}
+
+func (app *application) resolveFollowRequest(w http.ResponseWriter, r *http.Request, resolve func(ctx context.Context, userID, followerID int64) error) {
+	followerID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	user := app.getUserFromContext(r)
+	if err := resolve(r.Context(), user.ID, followerID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.noContentResponse(w)
+}
@@ -42,6 +42,16 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 	return writeJSON(w, status, &envelope{Data: data})
 }
 
+// paginatedJSONResponse writes a page of a list with the cursor of the next page,
+// which is left out on the last page.
+func (app *application) paginatedJSONResponse(w http.ResponseWriter, status int, data any, nextCursor string) error {
+	type envelope struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+	return writeJSON(w, status, &envelope{Data: data, NextCursor: nextCursor})
+}
+
 func (app *application) noContentResponse(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
 }
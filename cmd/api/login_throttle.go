@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/mailer"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type loginThrottleConfig struct {
+	enabled bool
+	// window is how long a failed login is remembered
+	window time.Duration
+	// freeAttempts is the number of failures allowed before delays are applied
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	// emailLockout and ipLockout are the numbers of failures that lock the email or the IP out
+	emailLockout    int
+	ipLockout       int
+	lockoutDuration time.Duration
+}
+
+// retryAfter returns how long a client has to wait before the next login attempt is allowed.
+// The delay doubles with every failure above freeAttempts, up to maxDelay.
+func (c *loginThrottleConfig) retryAfter(attempt *store.LoginAttempt, now time.Time) time.Duration {
+	if attempt == nil {
+		return 0
+	}
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if now.Sub(attempt.LastFailedAt) > c.window || attempt.Failures <= c.freeAttempts {
+		return 0
+	}
+	delay := c.maxDelay
+	if shift := attempt.Failures - c.freeAttempts - 1; shift < 32 {
+		delay = min(c.baseDelay<<shift, c.maxDelay)
+	}
+	return max(attempt.LastFailedAt.Add(delay).Sub(now), 0)
+}
+
+func (c *loginThrottleConfig) lockoutThreshold(scope string) int {
+	if scope == store.LoginScopeIP {
+		return c.ipLockout
+	}
+	return c.emailLockout
+}
+
+func (app *application) loginThrottleEnabled() bool {
+	return app.config.loginThrottle != nil && app.config.loginThrottle.enabled
+}
+
+// loginRetryAfter returns how long the client has to wait before trying to log in
+// with the email from its IP, 0 if it is allowed to try now.
+func (app *application) loginRetryAfter(ctx context.Context, email, ip string) (time.Duration, error) {
+	if !app.loginThrottleEnabled() {
+		return 0, nil
+	}
+	var retryAfter time.Duration
+	for scope, key := range map[string]string{store.LoginScopeEmail: normalizeEmail(email), store.LoginScopeIP: ip} {
+		attempt, err := app.store.LoginAttempts.Get(ctx, scope, key)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			return 0, err
+		}
+		retryAfter = max(retryAfter, app.config.loginThrottle.retryAfter(attempt, time.Now()))
+	}
+	return retryAfter, nil
+}
+
+// recordLoginFailure counts a failed login for the email and the IP and locks them out
+// once they reach the threshold. user is the owner of the email, nil if it is not registered.
+func (app *application) recordLoginFailure(ctx context.Context, email, ip string, user *store.User) {
+	if !app.loginThrottleEnabled() {
+		return
+	}
+	cfg := app.config.loginThrottle
+	for scope, key := range map[string]string{store.LoginScopeEmail: normalizeEmail(email), store.LoginScopeIP: ip} {
+		attempt, err := app.store.LoginAttempts.RecordFailure(ctx, scope, key, cfg.window)
+		if err != nil {
+			app.logger.Errorw("Failed to record login failure", "scope", scope, "error", err)
+			continue
+		}
+		now := time.Now()
+		if attempt.Failures < cfg.lockoutThreshold(scope) || (attempt.LockedUntil != nil && attempt.LockedUntil.After(now)) {
+			continue
+		}
+		if err := app.store.LoginAttempts.Lock(ctx, scope, key, now.Add(cfg.lockoutDuration)); err != nil {
+			app.logger.Errorw("Failed to lock out login", "scope", scope, "error", err)
+			continue
+		}
+		app.logger.Warnw("Login locked out", "scope", scope, "key", key, "failures", attempt.Failures)
+		if scope == store.LoginScopeEmail && user != nil {
+			app.sendLockoutNotification(user)
+		}
+	}
+}
+
+// resetLoginFailures forgets the failures of the email after a successful login.
+// Failures of the IP are kept so that one valid account does not unlock guessing others.
+func (app *application) resetLoginFailures(ctx context.Context, email string) {
+	if !app.loginThrottleEnabled() {
+		return
+	}
+	err := app.store.LoginAttempts.Reset(ctx, store.LoginScopeEmail, normalizeEmail(email))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		app.logger.Errorw("Failed to reset login failures", "error", err)
+	}
+}
+
+func (app *application) sendLockoutNotification(user *store.User) {
+	isProdEnv := app.config.env == "prodaction"
+	vars := struct {
+		Username          string
+		LockedFor         string
+		ForgotPasswordURL string
+	}{
+		Username:          user.Username,
+		LockedFor:         app.config.loginThrottle.lockoutDuration.String(),
+		ForgotPasswordURL: app.config.frontednURL + "/password/forgot",
+	}
+	if err := app.mailer.Send(mailer.AccountLockedTemplate, user.Username, user.Email, vars, !isProdEnv); err != nil {
+		app.logger.Errorw("unable to send email", "error", err)
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// getLockoutsHandler godoc
+//
+//	@Summary		Fetches login lockouts
+//	@Description	Fetches the emails and IPs that are locked out because of failed login attempts
+//	@Tags			lockouts
+//	@Produce		json
+//	@Success		200	{array}		store.LoginAttempt
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lockouts [get]
+func (app *application) getLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	attempts, err := app.store.LoginAttempts.GetLocked(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, attempts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getLoginAttemptHandler godoc
+//
+//	@Summary		Fetches login failures
+//	@Description	Fetches the failed login attempts and lockout of an email or IP
+//	@Tags			lockouts
+//	@Produce		json
+//	@Param			scope	path		string	true	"email or ip"
+//	@Param			key		path		string	true	"Email or IP"
+//	@Success		200		{object}	store.LoginAttempt
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lockouts/{scope}/{key} [get]
+func (app *application) getLoginAttemptHandler(w http.ResponseWriter, r *http.Request) {
+	scope, key, err := lockoutParams(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	attempt, err := app.store.LoginAttempts.Get(r.Context(), scope, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, attempt); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteLoginAttemptHandler godoc
+//
+//	@Summary		Lifts a login lockout
+//	@Description	Forgets the failed login attempts of an email or IP and lifts its lockout
+//	@Tags			lockouts
+//	@Param			scope	path		string	true	"email or ip"
+//	@Param			key		path		string	true	"Email or IP"
+//	@Success		204		{string}	string	"Lockout lifted"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/lockouts/{scope}/{key} [delete]
+func (app *application) deleteLoginAttemptHandler(w http.ResponseWriter, r *http.Request) {
+	scope, key, err := lockoutParams(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := app.store.LoginAttempts.Reset(r.Context(), scope, key); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.logger.Infow("Login lockout lifted", "scope", scope, "key", key, "by", app.getUserFromContext(r).ID)
+	app.noContentResponse(w)
+}
+
+func lockoutParams(r *http.Request) (string, string, error) {
+	scope := chi.URLParam(r, "scope")
+	key := chi.URLParam(r, "key")
+	switch scope {
+	case store.LoginScopeEmail:
+		return scope, normalizeEmail(key), nil
+	case store.LoginScopeIP:
+		return scope, key, nil
+	default:
+		return "", "", fmt.Errorf("unknown lockout scope %q", scope)
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"database/sql"
 	"expvar"
 	"fmt"
+	"net/netip"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/NikolayProkopchuk/social/internal/auth"
 	"github.com/NikolayProkopchuk/social/internal/db"
 	"github.com/NikolayProkopchuk/social/internal/env"
+	"github.com/NikolayProkopchuk/social/internal/events"
 	"github.com/NikolayProkopchuk/social/internal/mailer"
+	"github.com/NikolayProkopchuk/social/internal/ranking"
 	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/NikolayProkopchuk/social/internal/store/cache"
@@ -57,8 +61,9 @@ func main() {
 		},
 		env: env.GetString("ENV", "dev"),
 		mail: &mailConfig{
-			exp:       24 * time.Hour,
-			fromEmail: env.GetString("FROM_EMAIL", ""),
+			exp:              24 * time.Hour,
+			passwordResetExp: time.Hour,
+			fromEmail:        env.GetString("FROM_EMAIL", ""),
 			sendgrid: sendgridConfig{
 				apiKey: env.GetString("API_KEY", ""),
 			},
@@ -70,21 +75,79 @@ func main() {
 				password: env.GetString("BASIC_AUTH_PASSWORD", "password"),
 			},
 			tokenCfg: tokenConfig{
-				secret:   env.GetString("AUTH_TOKEN_SECRET", "example"),
-				issuer:   env.GetString("AUTH_TOKEN_ISSUER", "gopher.social"),
-				audience: env.GetString("AUTH_TOKEN_AUDIENCE", "gopher.social"),
-				exp:      time.Hour,
+				secret:       env.GetString("AUTH_TOKEN_SECRET", "example"),
+				issuer:       env.GetString("AUTH_TOKEN_ISSUER", "gopher.social"),
+				audience:     env.GetString("AUTH_TOKEN_AUDIENCE", "gopher.social"),
+				exp:          time.Hour,
+				refreshExp:   30 * 24 * time.Hour,
+				mfaExp:       5 * time.Minute,
+				mfaAttempts:  env.GetInt("MFA_MAX_ATTEMPTS", 5),
+				signingKeys:  env.GetString("AUTH_TOKEN_SIGNING_KEYS", ""),
+				signingKeyID: env.GetString("AUTH_TOKEN_SIGNING_KEY_ID", ""),
 			},
 		},
+		oidc: &oidcConfig{
+			enabled:      env.GetBool("OIDC_ENABLED", false),
+			provider:     env.GetString("OIDC_PROVIDER", "oidc"),
+			issuerURL:    env.GetString("OIDC_ISSUER_URL", ""),
+			clientID:     env.GetString("OIDC_CLIENT_ID", ""),
+			clientSecret: env.GetString("OIDC_CLIENT_SECRET", ""),
+			redirectURL:  env.GetString("OIDC_REDIRECT_URL", "http://localhost:8080/v1/authentication/oidc/callback"),
+		},
+		account: &accountConfig{
+			deletionPolicy: env.GetString("ACCOUNT_DELETION_POLICY", store.DeletionPolicyAnonymize),
+		},
+		timeline: &timelineConfig{
+			maxLength:          env.GetInt("TIMELINE_MAX_LENGTH", 800),
+			celebrityFollowers: env.GetInt("TIMELINE_CELEBRITY_FOLLOWERS", 10000),
+		},
+		ranking: &rankingConfig{
+			weights: ranking.Weights{
+				Recency:      env.GetFloat("FEED_RANKING_RECENCY_WEIGHT", 1),
+				Comments:     env.GetFloat("FEED_RANKING_COMMENTS_WEIGHT", 0.5),
+				Likes:        env.GetFloat("FEED_RANKING_LIKES_WEIGHT", 0.3),
+				Affinity:     env.GetFloat("FEED_RANKING_AFFINITY_WEIGHT", 0.4),
+				SecondDegree: env.GetFloat("FEED_RANKING_SECOND_DEGREE_WEIGHT", 0.5),
+				HalfLife:     time.Duration(env.GetInt("FEED_RANKING_HALF_LIFE_HOURS", 12)) * time.Hour,
+			},
+			window:     time.Duration(env.GetInt("FEED_RANKING_WINDOW_HOURS", 72)) * time.Hour,
+			candidates: env.GetInt("FEED_RANKING_CANDIDATES", 500),
+		},
+		comments: &commentsConfig{
+			maxDepth: env.GetInt("COMMENTS_MAX_DEPTH", 5),
+		},
+		events: &eventsConfig{
+			broker: env.GetString("EVENTS_BROKER", "memory"),
+		},
 		rateLimiter: &ratelimiter.Config{
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 			RequestsPerTimeFrame: env.GetInt("RATE_LIMITER_REQUESTS", 5),
 			TimeFrame:            time.Duration(env.GetInt("RATE_LIMITER_TIME_FRAME_SEC", 5)) * time.Second,
 		},
+		loginThrottle: &loginThrottleConfig{
+			enabled:         env.GetBool("LOGIN_THROTTLE_ENABLED", true),
+			window:          time.Duration(env.GetInt("LOGIN_THROTTLE_WINDOW_MIN", 15)) * time.Minute,
+			freeAttempts:    env.GetInt("LOGIN_THROTTLE_FREE_ATTEMPTS", 3),
+			baseDelay:       time.Second,
+			maxDelay:        30 * time.Second,
+			emailLockout:    env.GetInt("LOGIN_THROTTLE_EMAIL_LOCKOUT", 10),
+			ipLockout:       env.GetInt("LOGIN_THROTTLE_IP_LOCKOUT", 50),
+			lockoutDuration: time.Duration(env.GetInt("LOGIN_THROTTLE_LOCKOUT_MIN", 15)) * time.Minute,
+		},
 	}
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
+	trustedProxies, err := parseTrustedProxies(env.GetString("TRUSTED_PROXIES", ""))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	cfg.trustedProxies = trustedProxies
+
+	if policy := cfg.account.deletionPolicy; policy != store.DeletionPolicyAnonymize && policy != store.DeletionPolicyCascade {
+		logger.Fatalf("unknown account deletion policy %q", policy)
+	}
+
 	d, err := db.New(
 		cfg.db.url,
 		cfg.db.maxOpenCons,
@@ -108,10 +171,38 @@ func main() {
 		logger.Info("Redis client initialized")
 	}
 
+	var broker events.Broker
+	switch cfg.events.broker {
+	case "memory":
+		broker = events.NewMemoryBroker()
+	case "redis":
+		if redis == nil {
+			logger.Fatal("the redis events broker requires Redis to be enabled")
+		}
+		broker = events.NewRedisBroker(redis)
+	default:
+		logger.Fatalf("unknown events broker %q", cfg.events.broker)
+	}
+
 	mailerClient := mailer.NewSendGridMailer(cfg.mail.fromEmail, cfg.mail.sendgrid.apiKey)
-	authenticator := auth.NewJWTAuthenticator(cfg.auth.tokenCfg.secret, cfg.auth.tokenCfg.issuer, cfg.auth.tokenCfg.issuer)
+	authenticator, err := newAuthenticator(cfg.auth.tokenCfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
 	ratelimiter := ratelimiter.NewFixedWindowRateLimiter(cfg.rateLimiter.RequestsPerTimeFrame, cfg.rateLimiter.TimeFrame)
 
+	// left nil when disabled, which the handlers check
+	var oidcProvider auth.IdentityProvider
+	if cfg.oidc.enabled {
+		oidcProvider = auth.NewOIDCProvider(auth.OIDCConfig{
+			IssuerURL:    cfg.oidc.issuerURL,
+			ClientID:     cfg.oidc.clientID,
+			ClientSecret: cfg.oidc.clientSecret,
+			RedirectURL:  cfg.oidc.redirectURL,
+		})
+		logger.Infow("OIDC login enabled", "provider", cfg.oidc.provider, "issuer", cfg.oidc.issuerURL)
+	}
+
 	a := application{
 		config:        cfg,
 		store:         store.NewStorage(d),
@@ -120,6 +211,8 @@ func main() {
 		authenticator: authenticator,
 		cache:         cache.NewRedisStorage(redis),
 		rateLimiter:   ratelimiter,
+		oidc:          oidcProvider,
+		events:        broker,
 	}
 	expvar.NewString("version").Set(version)
 	expvar.Publish("database", expvar.Func(func() any {
@@ -131,3 +224,41 @@ func main() {
 	mux := a.mount()
 	logger.Fatal(a.run(mux))
 }
+
+// parseTrustedProxies parses a comma separated list of IP addresses and CIDR ranges.
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(item); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", item)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// newAuthenticator signs tokens with the HMAC secret unless signing keys are configured
+// as a comma separated list of kid=path/to/private.pem pairs.
+func newAuthenticator(cfg tokenConfig) (auth.Authenticator, error) {
+	if cfg.signingKeys == "" {
+		return auth.NewJWTAuthenticator(cfg.secret, cfg.audience, cfg.issuer), nil
+	}
+	keyFiles := make(map[string]string)
+	for _, pair := range strings.Split(cfg.signingKeys, ",") {
+		kid, path, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid signing key definition %q", pair)
+		}
+		keyFiles[kid] = path
+	}
+	return auth.NewKeySetAuthenticator(keyFiles, cfg.signingKeyID, cfg.audience, cfg.issuer)
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const recoveryCodesCount = 10
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// generateMFAToken issues a short-lived token proving that the password was verified.
+// It is only accepted by mfaChallengeHandler and can not be used as an access token.
+func (app *application) generateMFAToken(user *store.User) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"jti":               uuid.New().String(),
+		"sub":               user.ID,
+		"aud":               app.config.auth.tokenCfg.audience,
+		"iss":               app.config.auth.tokenCfg.issuer,
+		"exp":               now.Add(app.config.auth.tokenCfg.mfaExp).Unix(),
+		"iat":               now.Unix(),
+		issuedAtMicrosClaim: now.UnixMicro(),
+		"nbf":               now.Unix(),
+		"mfa":               "challenge",
+	}
+	return app.authenticator.GenerateToken(claims)
+}
+
+// mfaChallengeHandler godoc
+//
+//	@Summary		Completes a two-factor challenge
+//	@Description	Exchanges a two-factor challenge token and a TOTP or recovery code for access and refresh tokens
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFAChallengePayload	true	"Challenge token and code"
+//	@Success		201		{object}	TokenResponse		"Access and refresh tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/mfa/challenge [post]
+func (app *application) mfaChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MFAChallengePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	token, err := app.authenticator.ValidateToken(payload.MFAToken)
+	if err != nil {
+		app.unauthorizedError(w, r, fmt.Errorf("invalid mfa token: %v", err))
+		return
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if claims["mfa"] != "challenge" {
+		app.unauthorizedError(w, r, fmt.Errorf("invalid mfa token"))
+		return
+	}
+	userID, err := subjectFromClaims(claims)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+	jti, _ := claims["jti"].(string)
+	issuedAt, err := issuedAtFromClaims(claims)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		app.unauthorizedError(w, r, fmt.Errorf("invalid exp claim value"))
+		return
+	}
+
+	ctx := r.Context()
+	revoked, err := app.tokenDenylist().IsRevoked(ctx, jti, userID, issuedAt)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if revoked {
+		app.unauthorizedError(w, r, errMFATokenUsed)
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if !user.Active {
+		app.resourceForbiddenError(w, r, errUserNotActivated)
+		return
+	}
+	ip := clientIP(r)
+	retryAfter, err := app.loginRetryAfter(ctx, user.Email, ip)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.tooManyLoginAttemptsError(w, r, retryAfter)
+		return
+	}
+
+	if payload.Code != "" {
+		secret, err := app.store.MFA.GetSecret(ctx, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		step, ok := auth.MatchTOTP(secret, payload.Code, time.Now())
+		if !ok {
+			app.recordMFAFailure(ctx, jti, exp.Time, user, ip)
+			app.unauthorizedError(w, r, errInvalidTOTPCode)
+			return
+		}
+		if err := app.store.MFA.UseTOTPStep(ctx, user.ID, step); err != nil {
+			switch {
+			case errors.Is(err, store.ErrConflict):
+				// the code has been used already, possibly by whoever saw it being entered
+				app.recordMFAFailure(ctx, jti, exp.Time, user, ip)
+				app.unauthorizedError(w, r, errInvalidTOTPCode)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	} else {
+		err := app.store.MFA.UseRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(payload.RecoveryCode)))
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.recordMFAFailure(ctx, jti, exp.Time, user, ip)
+				app.unauthorizedError(w, r, fmt.Errorf("invalid recovery code"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	// the challenge token is single use, the claim fails for all but one of concurrent requests with it
+	claimed, err := app.tokenDenylist().Claim(ctx, jti, exp.Time)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !claimed {
+		app.unauthorizedError(w, r, errMFATokenUsed)
+		return
+	}
+
+	tokens, err := app.issueTokens(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// recordMFAFailure counts a wrong code for the challenge against the login throttle of the user and the IP,
+// and revokes the challenge once it has received mfaAttempts wrong codes, so that the code cannot be
+// guessed within the lifetime of the challenge.
+func (app *application) recordMFAFailure(ctx context.Context, jti string, expiresAt time.Time, user *store.User, ip string) {
+	app.recordLoginFailure(ctx, user.Email, ip, user)
+	attempt, err := app.store.LoginAttempts.RecordFailure(ctx, store.LoginScopeMFA, jti, app.config.auth.tokenCfg.mfaExp)
+	if err != nil {
+		app.logger.Errorw("Failed to record two-factor failure", "userID", user.ID, "error", err)
+		return
+	}
+	if attempt.Failures < app.config.auth.tokenCfg.mfaAttempts {
+		return
+	}
+	if err := app.tokenDenylist().Revoke(ctx, jti, expiresAt); err != nil {
+		app.logger.Errorw("Failed to revoke two-factor challenge", "userID", user.ID, "error", err)
+		return
+	}
+	app.logger.Warnw("Two-factor challenge revoked after failed attempts", "userID", user.ID, "failures", attempt.Failures)
+}
+
+var (
+	errMFATokenUsed    = errors.New("mfa token has already been used")
+	errInvalidTOTPCode = errors.New("invalid two-factor code")
+)
+
+type MFAChallengePayload struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=20"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// mfaEnrollHandler godoc
+//
+//	@Summary		Starts two-factor enrollment
+//	@Description	Generates a TOTP secret for the user. It is enforced only after it is verified with a code
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		201	{object}	MFAEnrollmentResponse
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/enroll [post]
+func (app *application) mfaEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserFromContext(r)
+	if user.MFAEnabled {
+		app.conflictError(w, r, errMFAAlreadyEnabled)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.MFA.SetSecret(r.Context(), user.ID, secret); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errMFAAlreadyEnabled)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	enrollment := &MFAEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(app.config.auth.tokenCfg.issuer, user.Email, secret),
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+var errMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// mfaVerifyHandler godoc
+//
+//	@Summary		Completes two-factor enrollment
+//	@Description	Verifies a TOTP code for the enrolled secret, enables two-factor authentication and returns recovery codes
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFAVerifyPayload	true	"TOTP code"
+//	@Success		200		{object}	MFARecoveryCodesResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/mfa/verify [post]
+func (app *application) mfaVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MFAVerifyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := app.getUserFromContext(r)
+	if user.MFAEnabled {
+		app.conflictError(w, r, errMFAAlreadyEnabled)
+		return
+	}
+	secret, err := app.store.MFA.GetSecret(r.Context(), user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestError(w, r, fmt.Errorf("two-factor enrollment has not been started"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	step, ok := auth.MatchTOTP(secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestError(w, r, errInvalidTOTPCode)
+		return
+	}
+	// the code that enabled two-factor authentication cannot be used again to log in
+	if err := app.store.MFA.UseTOTPStep(r.Context(), user.ID, step); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.badRequestError(w, r, errInvalidTOTPCode)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.MFA.Enable(r.Context(), user.ID, hashes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.invalidateCachedUsers(r.Context(), user.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, &MFARecoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type MFAVerifyPayload struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// requireMFAHandler godoc
+//
+//	@Summary		Requires two-factor authentication for roles
+//	@Description	Makes two-factor authentication mandatory for roles with level at or above the given one, 0 removes the requirement
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RequireMFAPayload	true	"Minimal role level"
+//	@Success		204		{string}	string				"Policy updated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/roles/mfa [put]
+func (app *application) requireMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RequireMFAPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	userIDs, err := app.store.Roles.RequireMFA(r.Context(), payload.Level)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// cached users carry the requirement of their role
+	app.invalidateCachedUsers(r.Context(), userIDs...)
+
+	app.noContentResponse(w)
+}
+
+type RequireMFAPayload struct {
+	Level int `json:"level" validate:"gte=0"`
+}
+
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		codes[i] = hex.EncodeToString(b)
+		hashes[i] = hashToken(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
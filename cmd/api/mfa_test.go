@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/NikolayProkopchuk/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMFAChallenge(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		auth: &authConfig{
+			tokenCfg: tokenConfig{
+				audience:    "gopher.social",
+				issuer:      "gopher.social",
+				exp:         time.Hour,
+				refreshExp:  time.Hour,
+				mfaExp:      5 * time.Minute,
+				mfaAttempts: 3,
+			},
+		},
+	}
+	user := &store.User{ID: 2, Email: "test@mail.com", Active: true, MFAEnabled: true}
+	// the challenge claims are checked, so a real authenticator is needed
+	newApp := func(t *testing.T, cfg config) (*application, http.Handler, string) {
+		t.Helper()
+		app := newTestApp(t, cfg)
+		app.authenticator = auth.NewJWTAuthenticator("secret", cfg.auth.tokenCfg.audience, cfg.auth.tokenCfg.issuer)
+		mfaToken, err := app.generateMFAToken(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return app, app.mount(), mfaToken
+	}
+	challenge := func(t *testing.T, mux http.Handler, payload MFAChallengePayload) int {
+		t.Helper()
+		body, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/v1/authentication/mfa/challenge", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should revoke the challenge after too many wrong codes", func(t *testing.T) {
+		app, mux, mfaToken := newApp(t, cfg)
+		mockMFAStore := app.store.MFA.(*store.MockMFAStore)
+		mockMFAStore.On("UseRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(store.ErrNotFound)
+		mockLoginAttemptStore := app.store.LoginAttempts.(*store.MockLoginAttemptStore)
+		for failures := 1; failures <= 3; failures++ {
+			mockLoginAttemptStore.On("RecordFailure", mock.Anything, store.LoginScopeMFA, mock.Anything, cfg.auth.tokenCfg.mfaExp).
+				Return(&store.LoginAttempt{Scope: store.LoginScopeMFA, Failures: failures, LastFailedAt: time.Now()}, nil).Once()
+		}
+		mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+		mockRevokedTokenStore.On("Revoke", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		for i := 0; i < 2; i++ {
+			checkResponseCode(t, http.StatusUnauthorized, challenge(t, mux, MFAChallengePayload{MFAToken: mfaToken, RecoveryCode: "wrong"}))
+			mockRevokedTokenStore.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything, mock.Anything)
+		}
+		checkResponseCode(t, http.StatusUnauthorized, challenge(t, mux, MFAChallengePayload{MFAToken: mfaToken, RecoveryCode: "wrong"}))
+		mockRevokedTokenStore.AssertNumberOfCalls(t, "Revoke", 1)
+	})
+
+	t.Run("should issue tokens for a challenge only once", func(t *testing.T) {
+		app, mux, mfaToken := newApp(t, cfg)
+		mockMFAStore := app.store.MFA.(*store.MockMFAStore)
+		mockMFAStore.On("UseRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(nil)
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil)
+		// the second request lost the race for the challenge after its code was accepted
+		mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+		mockRevokedTokenStore.On("Claim", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Once()
+		mockRevokedTokenStore.On("Claim", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+		checkResponseCode(t, http.StatusCreated, challenge(t, mux, MFAChallengePayload{MFAToken: mfaToken, RecoveryCode: "code"}))
+		checkResponseCode(t, http.StatusUnauthorized, challenge(t, mux, MFAChallengePayload{MFAToken: mfaToken, RecoveryCode: "code"}))
+		mockRefreshTokenStore.AssertNumberOfCalls(t, "Create", 1)
+	})
+
+	t.Run("should not accept a TOTP code twice", func(t *testing.T) {
+		app, mux, mfaToken := newApp(t, cfg)
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := auth.GenerateTOTP(secret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		mockMFAStore := app.store.MFA.(*store.MockMFAStore)
+		mockMFAStore.On("GetSecret", mock.Anything, user.ID).Return(secret, nil)
+		mockMFAStore.On("UseTOTPStep", mock.Anything, user.ID, mock.Anything).Return(nil).Once()
+		mockMFAStore.On("UseTOTPStep", mock.Anything, user.ID, mock.Anything).Return(store.ErrConflict)
+		mockLoginAttemptStore := app.store.LoginAttempts.(*store.MockLoginAttemptStore)
+		mockLoginAttemptStore.On("RecordFailure", mock.Anything, store.LoginScopeMFA, mock.Anything, mock.Anything).
+			Return(&store.LoginAttempt{Scope: store.LoginScopeMFA, Failures: 1, LastFailedAt: time.Now()}, nil)
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+		mockRevokedTokenStore.On("Claim", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+
+		checkResponseCode(t, http.StatusCreated, challenge(t, mux, MFAChallengePayload{MFAToken: mfaToken, Code: code}))
+		// a new challenge, as after logging in with the password again
+		secondToken, err := app.generateMFAToken(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkResponseCode(t, http.StatusUnauthorized, challenge(t, mux, MFAChallengePayload{MFAToken: secondToken, Code: code}))
+		mockRefreshTokenStore.AssertNumberOfCalls(t, "Create", 1)
+	})
+
+	t.Run("should apply the login throttle to the challenge", func(t *testing.T) {
+		throttled := cfg
+		throttled.loginThrottle = &loginThrottleConfig{
+			enabled:         true,
+			window:          15 * time.Minute,
+			freeAttempts:    3,
+			baseDelay:       time.Second,
+			maxDelay:        30 * time.Second,
+			emailLockout:    10,
+			ipLockout:       50,
+			lockoutDuration: 15 * time.Minute,
+		}
+		app, mux, mfaToken := newApp(t, throttled)
+		lockedUntil := time.Now().Add(10 * time.Minute)
+		mockLoginAttemptStore := app.store.LoginAttempts.(*store.MockLoginAttemptStore)
+		mockLoginAttemptStore.On("Get", mock.Anything, store.LoginScopeEmail, user.Email).Return(
+			&store.LoginAttempt{Failures: 10, LastFailedAt: time.Now(), LockedUntil: &lockedUntil}, nil)
+		mockLoginAttemptStore.On("Get", mock.Anything, store.LoginScopeIP, mock.Anything).Return(nil, store.ErrNotFound)
+
+		checkResponseCode(t, http.StatusTooManyRequests, challenge(t, mux, MFAChallengePayload{MFAToken: mfaToken, RecoveryCode: "wrong"}))
+		mockMFAStore := app.store.MFA.(*store.MockMFAStore)
+		mockMFAStore.AssertNotCalled(t, "UseRecoveryCode", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRequireMFA(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: true,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockRoleStore := app.store.Roles.(*store.MockRoleStore)
+	// the moderator of the test app is let in as an admin
+	mockRoleStore.On("GetByName", mock.Anything, "admin").Return(&store.Role{ID: 2, Name: "admin", Level: 50}, nil)
+	mockRoleStore.On("RequireMFA", mock.Anything, 50).Return([]int64{1, 2}, nil)
+	mockUserCache := app.cache.Users.(*cache.MockUserCache)
+	mockUserCache.On("Delete", mock.Anything, []int64{1, 2}).Return(nil)
+
+	req, err := http.NewRequest("PUT", "/v1/roles/mfa", strings.NewReader(`{"level": 50}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusNoContent, rr.Code)
+	// the cached users would keep the previous requirement of their role
+	mockUserCache.AssertCalled(t, "Delete", mock.Anything, []int64{1, 2})
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -27,9 +30,64 @@ func (app *application) basicAuthMiddleware() func(http.Handler) http.Handler {
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey          contextKey = "user"
+	claimsContextKey        contextKey = "claims"
+	personalTokenContextKey contextKey = "personalToken"
+)
+
+var (
+	errUserNotActivated        = errors.New("user account is not activated")
+	errMFAEnrollmentRequired   = errors.New("two-factor authentication is required for your role, enroll first")
+	errPersonalTokenNotAllowed = errors.New("personal access tokens can not be used for this endpoint")
+)
+
+func subjectFromClaims(claims jwt.MapClaims) (int64, error) {
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sub claim value")
+	}
+	return userID, nil
+}
+
+// issuedAtMicrosClaim holds the issue time of a token in microseconds. The whole seconds of iat can not tell
+// the tokens issued just before the sessions of the user were revoked from the ones issued right after.
+const issuedAtMicrosClaim = "iat_us"
 
+// issuedAtFromClaims returns the issue time of the token, to the microsecond when the token has it.
+func issuedAtFromClaims(claims jwt.MapClaims) (time.Time, error) {
+	if micros, ok := claims[issuedAtMicrosClaim].(float64); ok {
+		return time.UnixMicro(int64(micros)), nil
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return time.Time{}, fmt.Errorf("invalid iat claim value")
+	}
+	return issuedAt.Time, nil
+}
+
+// authTokentMiddleware authenticates the request with an access token. Personal tokens are not accepted.
 func (app *application) authTokentMiddleware(next http.Handler) http.Handler {
+	return app.authenticate(next, true, "")
+}
+
+// scopedAuthMiddleware authenticates like authTokentMiddleware, but also accepts personal tokens
+// that have the scope.
+func (app *application) scopedAuthMiddleware(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return app.authenticate(next, true, scope)
+	}
+}
+
+// mfaEnrollmentMiddleware authenticates like authTokentMiddleware, but also lets in users whose
+// role requires two-factor authentication before they have enrolled.
+func (app *application) mfaEnrollmentMiddleware(next http.Handler) http.Handler {
+	return app.authenticate(next, false, "")
+}
+
+// authenticate resolves the user of the request. Personal tokens are rejected unless scope is set,
+// and then accepted only when they have it.
+func (app *application) authenticate(next http.Handler, enforceMFA bool, scope string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -42,37 +100,114 @@ func (app *application) authTokentMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		tokenString := headerParts[1]
-		token, err := app.authenticator.ValidateToken(tokenString)
-		if err != nil {
-			app.unauthorizedError(w, r, fmt.Errorf("invalid token: %v", err))
-			return
-		}
-		claims, _ := token.Claims.(jwt.MapClaims)
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
-		if err != nil {
-			app.unauthorizedError(w, r, fmt.Errorf("invalid sub claim value"))
-			return
-		}
 		ctx := r.Context()
+
+		var userID int64
+		if strings.HasPrefix(tokenString, personalTokenPrefix) {
+			if scope == "" {
+				app.unauthorizedError(w, r, errPersonalTokenNotAllowed)
+				return
+			}
+			personalToken, err := app.store.PersonalTokens.Use(ctx, hashToken(tokenString))
+			if err != nil {
+				switch {
+				case errors.Is(err, store.ErrNotFound):
+					app.unauthorizedError(w, r, fmt.Errorf("invalid token"))
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
+			if !personalToken.HasScope(scope) {
+				app.resourceForbiddenError(w, r, fmt.Errorf("token does not have the %s scope", scope))
+				return
+			}
+			userID = personalToken.UserID
+			ctx = context.WithValue(ctx, personalTokenContextKey, personalToken)
+		} else {
+			token, err := app.authenticator.ValidateToken(tokenString)
+			if err != nil {
+				app.unauthorizedError(w, r, fmt.Errorf("invalid token: %v", err))
+				return
+			}
+			claims, _ := token.Claims.(jwt.MapClaims)
+			if claims["mfa"] != nil || claims["oidc"] != nil {
+				app.unauthorizedError(w, r, fmt.Errorf("token can not be used for authentication"))
+				return
+			}
+			userID, err = subjectFromClaims(claims)
+			if err != nil {
+				app.unauthorizedError(w, r, err)
+				return
+			}
+			jti, ok := claims["jti"].(string)
+			if !ok || jti == "" {
+				app.unauthorizedError(w, r, fmt.Errorf("invalid jti claim value"))
+				return
+			}
+			issuedAt, err := issuedAtFromClaims(claims)
+			if err != nil {
+				app.unauthorizedError(w, r, err)
+				return
+			}
+			revoked, err := app.tokenDenylist().IsRevoked(ctx, jti, userID, issuedAt)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if revoked {
+				app.unauthorizedError(w, r, fmt.Errorf("token has been revoked"))
+				return
+			}
+			ctx = context.WithValue(ctx, claimsContextKey, claims)
+		}
+
 		user, err := app.getUser(ctx, userID)
 		if err != nil {
 			app.logger.Warn(err)
 			app.unauthorizedError(w, r, fmt.Errorf("user not found"))
 			return
 		}
+		if !user.Active {
+			app.resourceForbiddenError(w, r, errUserNotActivated)
+			return
+		}
+		if enforceMFA && user.Role.MFARequired && !user.MFAEnabled {
+			app.resourceForbiddenError(w, r, errMFAEnrollmentRequired)
+			return
+		}
 		ctx = context.WithValue(ctx, userContextKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// optionalAuthMiddleware authenticates the request like authTokentMiddleware when it carries
+// credentials and lets anonymous requests through.
+func (app *application) optionalAuthMiddleware(next http.Handler) http.Handler {
+	authenticated := app.authTokentMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) postOwnershipMiddleware(roleName string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.getUserFromContext(r)
 		post := app.getPostFromContext(r)
+		personalToken := app.getPersonalTokenFromContext(r)
 		if post.UserID == user.ID {
 			next.ServeHTTP(w, r)
 			return
 		}
+		// personal tokens act only on resources of their owner, never with the privileges of the role
+		if personalToken != nil {
+			app.resourceForbiddenError(w, r, fmt.Errorf("post modification is allowed only for owner"))
+			return
+		}
 		role, err := app.store.Roles.GetByName(r.Context(), roleName)
 		if err != nil {
 			app.internalServerError(w, r, err)
@@ -87,6 +222,53 @@ func (app *application) postOwnershipMiddleware(roleName string, next http.Handl
 	})
 }
 
+// commentOwnershipMiddleware lets the author of the comment, the author of the post when allowPostOwner is set,
+// and users with at least roleName through to next.
+func (app *application) commentOwnershipMiddleware(roleName string, allowPostOwner bool, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.getUserFromContext(r)
+		post := app.getPostFromContext(r)
+		comment := app.getCommentFromContext(r)
+		personalToken := app.getPersonalTokenFromContext(r)
+		if comment.User.ID == user.ID || (allowPostOwner && post.UserID == user.ID) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// personal tokens act only on resources of their owner, never with the privileges of the role
+		if personalToken != nil {
+			app.resourceForbiddenError(w, r, fmt.Errorf("comment modification is allowed only for owner"))
+			return
+		}
+		role, err := app.store.Roles.GetByName(r.Context(), roleName)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if user.Role.Level >= role.Level {
+			next.ServeHTTP(w, r)
+			return
+		}
+		app.resourceForbiddenError(w, r, fmt.Errorf("comment modification is allowed only for owner or users with %s role", roleName))
+	})
+}
+
+func (app *application) roleMiddleware(roleName string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.getUserFromContext(r)
+		role, err := app.store.Roles.GetByName(r.Context(), roleName)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if user.Role.Level < role.Level {
+			app.resourceForbiddenError(w, r, fmt.Errorf("access is allowed only for users with %s role", roleName))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
 	if !app.config.redis.enabled {
 		return app.store.Users.GetByID(ctx, userID)
@@ -113,6 +295,75 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 	return user, err
 }
 
+// tokenDenylist returns the denylist consulted for revoked access tokens:
+// Redis when it is enabled, Postgres otherwise.
+func (app *application) tokenDenylist() store.TokenDenylist {
+	if app.config.redis.enabled {
+		return app.cache.RevokedTokens
+	}
+	return app.store.RevokedTokens
+}
+
+func (app *application) invalidateCachedUsers(ctx context.Context, userIDs ...int64) {
+	if !app.config.redis.enabled || len(userIDs) == 0 {
+		return
+	}
+	if err := app.cache.Users.Delete(ctx, userIDs...); err != nil {
+		app.logger.Errorw("Failed to invalidate cached users", "userIDs", userIDs, "error", err)
+	}
+}
+
+// realIPMiddleware replaces the remote address of a request forwarded by a trusted proxy with the address
+// of the client. The forwarding headers of other requests are ignored, as any client can set them to dodge
+// or to trigger the lockouts of the login throttle.
+func (app *application) realIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := app.forwardedClientAddr(r); ok {
+			r.RemoteAddr = addr.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClientAddr returns the client address forwarded by the trusted proxies the request came through.
+// Proxies append the address they see to X-Forwarded-For, so the client is the last address that is not
+// a trusted proxy; the addresses before it are set by the client.
+func (app *application) forwardedClientAddr(r *http.Request) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !app.isTrustedProxy(peer.Addr()) {
+		return netip.Addr{}, false
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if !app.isTrustedProxy(addr) {
+			return addr.Unmap(), true
+		}
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func (app *application) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, proxy := range app.config.trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 func (app *application) userOwnershipMiddleware(roleName string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.getUserFromContext(r)
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateExp    = 10 * time.Minute
+)
+
+var errOIDCDisabled = errors.New("oidc login is not enabled")
+
+// oidcLoginHandler godoc
+//
+//	@Summary		Starts OIDC login
+//	@Description	Redirects to the configured OpenID Connect provider using the authorization code flow with PKCE
+//	@Tags			authentication
+//	@Success		302
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/authentication/oidc/login [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		app.resourceNotFound(w, r, errOIDCDisabled)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := generateRandomToken()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	redirectURL, err := app.oidc.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the flow state travels in a signed short-lived cookie so that any API instance can complete the login
+	claims := jwt.MapClaims{
+		"aud":      app.config.auth.tokenCfg.audience,
+		"iss":      app.config.auth.tokenCfg.issuer,
+		"exp":      time.Now().Add(oidcStateExp).Unix(),
+		"iat":      time.Now().Unix(),
+		"oidc":     "state",
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+	}
+	stateToken, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	http.SetCookie(w, app.oidcStateCookie(stateToken, int(oidcStateExp.Seconds())))
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes OIDC login
+//	@Description	Exchanges the authorization code, creates or links the user by verified email and issues tokens
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			code	query		string					true	"Authorization code"
+//	@Param			state	query		string					true	"State"
+//	@Success		201		{object}	TokenResponse			"Access and refresh tokens"
+//	@Success		200		{object}	MFAChallengeResponse	"Two-factor challenge"
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/oidc/callback [get]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		app.resourceNotFound(w, r, errOIDCDisabled)
+		return
+	}
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		app.unauthorizedError(w, r, fmt.Errorf("oidc provider returned an error: %s", providerErr))
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		app.unauthorizedError(w, r, fmt.Errorf("missing oidc state"))
+		return
+	}
+	http.SetCookie(w, app.oidcStateCookie("", -1))
+
+	token, err := app.authenticator.ValidateToken(cookie.Value)
+	if err != nil {
+		app.unauthorizedError(w, r, fmt.Errorf("invalid oidc state: %v", err))
+		return
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	state, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+	if claims["oidc"] != "state" || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		app.unauthorizedError(w, r, fmt.Errorf("oidc state mismatch"))
+		return
+	}
+
+	identity, err := app.oidc.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		app.unauthorizedError(w, r, fmt.Errorf("oidc login failed: %v", err))
+		return
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		app.resourceForbiddenError(w, r, fmt.Errorf("the identity provider did not verify the email"))
+		return
+	}
+
+	user, err := app.findOrCreateOIDCUser(r.Context(), identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.completeLogin(w, r, user)
+}
+
+// findOrCreateOIDCUser returns the user linked to the identity. When there is none, the identity
+// is linked to the user with the same verified email or a new active user is registered.
+func (app *application) findOrCreateOIDCUser(ctx context.Context, identity *auth.OIDCIdentity) (*store.User, error) {
+	provider := app.config.oidc.provider
+	userID, err := app.store.Identities.GetUserID(ctx, provider, identity.Subject)
+	if err == nil {
+		return app.store.Users.GetByID(ctx, userID)
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, identity.Email)
+	if err == nil {
+		if err := app.store.Identities.Link(ctx, user.ID, provider, identity.Subject); err != nil {
+			return nil, err
+		}
+		app.logger.Infow("Linked oidc identity", "userID", user.ID, "provider", provider)
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	userRole, err := app.store.Roles.GetByName(ctx, "user")
+	if err != nil {
+		return nil, err
+	}
+	username, err := usernameFromEmail(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	user = &store.User{
+		Username: username,
+		Email:    identity.Email,
+		Role:     *userRole,
+		Active:   true,
+	}
+	// the account is only reachable through the provider until the user resets the password
+	password, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := user.Password.Set(password); err != nil {
+		return nil, err
+	}
+	if err := app.store.Identities.CreateUser(ctx, user, provider, identity.Subject); err != nil {
+		return nil, err
+	}
+	app.logger.Infow("Registered user through oidc", "userID", user.ID, "provider", provider)
+	return user, nil
+}
+
+var usernameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func usernameFromEmail(email string) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+	local = usernameUnsafeChars.ReplaceAllString(local, "")
+	if len(local) > 50 {
+		local = local[:50]
+	}
+	if local == "" {
+		local = "user"
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return local + "-" + hex.EncodeToString(suffix), nil
+}
+
+func (app *application) oidcStateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/v1/authentication/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   app.config.env == "prodaction",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOIDCCallback(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		auth: &authConfig{
+			tokenCfg: tokenConfig{
+				audience:   "gopher.social",
+				issuer:     "gopher.social",
+				exp:        time.Hour,
+				refreshExp: time.Hour,
+			},
+		},
+		oidc: &oidcConfig{
+			enabled:  true,
+			provider: "mock",
+		},
+	}
+	verified := &auth.OIDCIdentity{Subject: "provider-user-1", Email: "oidc@mail.com", EmailVerified: true}
+	// the state cookie is checked, so a real authenticator is needed
+	newApp := func(t *testing.T, identity *auth.OIDCIdentity) (*application, http.Handler) {
+		t.Helper()
+		app := newTestApp(t, cfg)
+		app.authenticator = auth.NewJWTAuthenticator("secret", cfg.auth.tokenCfg.audience, cfg.auth.tokenCfg.issuer)
+		app.oidc = &auth.MockIdentityProvider{Identity: identity}
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.On("Create", mock.Anything, mock.Anything).Return(nil)
+		return app, app.mount()
+	}
+	// login starts the flow and returns the state cookie and the state sent to the provider
+	login := func(t *testing.T, mux http.Handler) (*http.Cookie, string) {
+		t.Helper()
+		req, err := http.NewRequest("GET", "/v1/authentication/oidc/login", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusFound, rr.Code)
+		redirect, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != oidcStateCookie {
+			t.Fatalf("expected the state cookie, got %v", cookies)
+		}
+		return cookies[0], redirect.Query().Get("state")
+	}
+	callback := func(t *testing.T, mux http.Handler, cookie *http.Cookie, state string) int {
+		t.Helper()
+		req, err := http.NewRequest("GET", "/v1/authentication/oidc/callback?code=code&state="+url.QueryEscape(state), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(cookie)
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should reject a state that does not match the cookie", func(t *testing.T) {
+		app, mux := newApp(t, verified)
+		cookie, _ := login(t, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, callback(t, mux, cookie, "other"))
+		mockIdentityStore := app.store.Identities.(*store.MockIdentityStore)
+		mockIdentityStore.AssertNotCalled(t, "GetUserID", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject a code verifier of another login", func(t *testing.T) {
+		app, mux := newApp(t, verified)
+		cookie, state := login(t, mux)
+		// the provider now expects the code verifier of the second login
+		login(t, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, callback(t, mux, cookie, state))
+		mockIdentityStore := app.store.Identities.(*store.MockIdentityStore)
+		mockIdentityStore.AssertNotCalled(t, "GetUserID", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should refuse to link an unverified email", func(t *testing.T) {
+		unverified := &auth.OIDCIdentity{Subject: "provider-user-2", Email: "test@mail.com", EmailVerified: false}
+		app, mux := newApp(t, unverified)
+		cookie, state := login(t, mux)
+
+		checkResponseCode(t, http.StatusForbidden, callback(t, mux, cookie, state))
+		mockIdentityStore := app.store.Identities.(*store.MockIdentityStore)
+		mockIdentityStore.AssertNotCalled(t, "Link", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should sign in the user of a linked identity", func(t *testing.T) {
+		app, mux := newApp(t, verified)
+		mockIdentityStore := app.store.Identities.(*store.MockIdentityStore)
+		mockIdentityStore.On("GetUserID", mock.Anything, "mock", "provider-user-1").Return(int64(2), nil)
+		cookie, state := login(t, mux)
+
+		checkResponseCode(t, http.StatusCreated, callback(t, mux, cookie, state))
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.AssertCalled(t, "Create", mock.Anything, mock.MatchedBy(func(token *store.RefreshToken) bool {
+			return token.UserID == 2
+		}))
+		mockIdentityStore.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should link the identity to the user with the verified email", func(t *testing.T) {
+		app, mux := newApp(t, verified)
+		mockIdentityStore := app.store.Identities.(*store.MockIdentityStore)
+		mockIdentityStore.On("GetUserID", mock.Anything, "mock", "provider-user-1").Return(int64(0), store.ErrNotFound)
+		mockIdentityStore.On("Link", mock.Anything, int64(2), "mock", "provider-user-1").Return(nil)
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("GetByEmail", mock.Anything, "oidc@mail.com").Return(
+			&store.User{ID: 2, Email: "oidc@mail.com", Active: true}, nil)
+		cookie, state := login(t, mux)
+
+		checkResponseCode(t, http.StatusCreated, callback(t, mux, cookie, state))
+		mockIdentityStore.AssertCalled(t, "Link", mock.Anything, int64(2), "mock", "provider-user-1")
+	})
+
+	t.Run("should register a new user", func(t *testing.T) {
+		app, mux := newApp(t, verified)
+		mockIdentityStore := app.store.Identities.(*store.MockIdentityStore)
+		mockIdentityStore.On("GetUserID", mock.Anything, "mock", "provider-user-1").Return(int64(0), store.ErrNotFound)
+		mockIdentityStore.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *store.User) bool {
+			return u.Email == "oidc@mail.com" && u.Active && u.Role.Name == "user"
+		}), "mock", "provider-user-1").Run(func(args mock.Arguments) {
+			args.Get(1).(*store.User).ID = 4
+		}).Return(nil)
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("GetByEmail", mock.Anything, "oidc@mail.com").Return(nil, store.ErrNotFound)
+		mockRoleStore := app.store.Roles.(*store.MockRoleStore)
+		mockRoleStore.On("GetByName", mock.Anything, "user").Return(&store.Role{ID: 1, Name: "user", Level: 10}, nil)
+		cookie, state := login(t, mux)
+
+		checkResponseCode(t, http.StatusCreated, callback(t, mux, cookie, state))
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.AssertCalled(t, "Create", mock.Anything, mock.MatchedBy(func(token *store.RefreshToken) bool {
+			return token.UserID == 4
+		}))
+	})
+}
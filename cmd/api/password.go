@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/NikolayProkopchuk/social/internal/mailer"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/google/uuid"
+)
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Sends a password reset link to the email if it belongs to a registered user
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Password reset requested"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// respond the same way as for a known email to not reveal registered accounts
+			app.logger.Infow("password reset requested for unknown email", "email", payload.Email)
+			if err := app.jsonResponse(w, http.StatusAccepted, ""); err != nil {
+				app.internalServerError(w, r, err)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	plainToken := uuid.New().String()
+	if err := app.store.Users.CreatePasswordReset(r.Context(), user.ID, hashToken(plainToken), app.config.mail.passwordResetExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	isProdEnv := app.config.env == "prodaction"
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  app.config.frontednURL + "/password/reset?token=" + plainToken,
+		ExpiresIn: app.config.mail.passwordResetExp.String(),
+	}
+
+	if err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv); err != nil {
+		app.logger.Errorw("unable to send email", "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, ""); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using a password reset token and revokes all existing sessions
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user := &store.User{}
+	if err := user.Password.Set(payload.Password); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.ResetPassword(r.Context(), hashToken(payload.Token), user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.revokeUserSessions(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.noContentResponse(w)
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,uuid"`
+	Password string `json:"password" validate:"required,min=8,max=100"`
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/mailer"
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestForgotPassword(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		mail: &mailConfig{
+			passwordResetExp: time.Hour,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedSent   bool
+	}{
+		{
+			name:           "should send a reset link to a registered email",
+			body:           `{"email": "test@mail.com"}`,
+			expectedStatus: http.StatusAccepted,
+			expectedSent:   true,
+		},
+		{
+			name:           "should accept an unknown email without sending anything",
+			body:           `{"email": "unknown@mail.com"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "should reject an invalid email",
+			body:           `{"email": "not-an-email"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestApp(t, cfg)
+			mux := app.mount()
+
+			mockUserStore := app.store.Users.(*store.MockUserStore)
+			mockUserStore.On("GetByEmail", mock.Anything, "test@mail.com").Return(
+				&store.User{ID: 2, Username: "TestUser", Email: "test@mail.com"}, nil)
+			mockUserStore.On("GetByEmail", mock.Anything, "unknown@mail.com").Return(nil, store.ErrNotFound)
+			mockUserStore.On("CreatePasswordReset", mock.Anything, int64(2), mock.Anything, time.Hour).Return(nil)
+			mockMailer := app.mailer.(*mailer.MockClient)
+			mockMailer.On("Send", mailer.PasswordResetTemplate, "TestUser", "test@mail.com", mock.Anything, true).Return(nil)
+
+			req, err := http.NewRequest("POST", "/v1/authentication/password/forgot", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedSent {
+				mockUserStore.AssertNumberOfCalls(t, "CreatePasswordReset", 1)
+				mockMailer.AssertNumberOfCalls(t, "Send", 1)
+			} else {
+				mockUserStore.AssertNotCalled(t, "CreatePasswordReset", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				mockMailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		auth: &authConfig{
+			tokenCfg: tokenConfig{
+				exp: time.Hour,
+			},
+		},
+	}
+	validToken := "0b8d1f4e-6c1a-4f63-9a57-2f0e4c6d8b10"
+	usedToken := "5e3c2a1b-7d4f-4e8a-b6c9-0a1b2c3d4e5f"
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedReset  bool
+	}{
+		{
+			name:           "should set the new password and revoke the sessions",
+			body:           `{"token": "` + validToken + `", "password": "new-password"}`,
+			expectedStatus: http.StatusNoContent,
+			expectedReset:  true,
+		},
+		{
+			name:           "should reject an expired or used token",
+			body:           `{"token": "` + usedToken + `", "password": "new-password"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should reject a malformed token",
+			body:           `{"token": "token", "password": "new-password"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject a short password",
+			body:           `{"token": "` + validToken + `", "password": "short"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestApp(t, cfg)
+			mux := app.mount()
+
+			mockUserStore := app.store.Users.(*store.MockUserStore)
+			mockUserStore.On("ResetPassword", mock.Anything, hashToken(validToken), mock.MatchedBy(func(u *store.User) bool {
+				return u.Password.Compare("new-password") == nil
+			})).Run(func(args mock.Arguments) {
+				args.Get(2).(*store.User).ID = 2
+			}).Return(nil)
+			mockUserStore.On("ResetPassword", mock.Anything, hashToken(usedToken), mock.Anything).Return(store.ErrNotFound)
+			mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+			mockRevokedTokenStore.On("RevokeUser", mock.Anything, int64(2), mock.Anything).Return(nil)
+			mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+			mockRefreshTokenStore.On("RevokeAllForUser", mock.Anything, int64(2)).Return(nil)
+
+			req, err := http.NewRequest("POST", "/v1/authentication/password/reset", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedReset {
+				mockRevokedTokenStore.AssertCalled(t, "RevokeUser", mock.Anything, int64(2), mock.Anything)
+				mockRefreshTokenStore.AssertCalled(t, "RevokeAllForUser", mock.Anything, int64(2))
+			} else {
+				mockRevokedTokenStore.AssertNotCalled(t, "RevokeUser", mock.Anything, mock.Anything, mock.Anything)
+				mockRefreshTokenStore.AssertNotCalled(t, "RevokeAllForUser", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
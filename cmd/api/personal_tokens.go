@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// personalTokenPrefix tells personal tokens apart from JWT access tokens in the Authorization header.
+const personalTokenPrefix = "gsp_"
+
+type CreatePersonalTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=posts:write comments:write feed:read"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type PersonalTokenResponse struct {
+	*store.PersonalToken
+	Token string `json:"token"`
+}
+
+// createPersonalTokenHandler godoc
+//
+//	@Summary		Creates a personal access token
+//	@Description	Creates a long-lived token with the given scopes. The token is returned only once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreatePersonalTokenPayload	true	"Token name, scopes and expiration"
+//	@Success		201		{object}	PersonalTokenResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [post]
+func (app *application) createPersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreatePersonalTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	random, err := generateRandomToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	plainToken := personalTokenPrefix + random
+
+	user := app.getUserFromContext(r)
+	personalToken := &store.PersonalToken{
+		UserID:    user.ID,
+		Name:      payload.Name,
+		TokenHash: hashToken(plainToken),
+		Scopes:    payload.Scopes,
+	}
+	if payload.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, payload.ExpiresInDays)
+		personalToken.ExpiresAt = &expiresAt
+	}
+	if err := app.store.PersonalTokens.Create(r.Context(), personalToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := &PersonalTokenResponse{PersonalToken: personalToken, Token: plainToken}
+	if err := app.jsonResponse(w, http.StatusCreated, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getPersonalTokensHandler godoc
+//
+//	@Summary		Fetches personal access tokens
+//	@Description	Fetches the personal access tokens of the user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.PersonalToken
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens [get]
+func (app *application) getPersonalTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserFromContext(r)
+	tokens, err := app.store.PersonalTokens.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deletePersonalTokenHandler godoc
+//
+//	@Summary		Revokes a personal access token
+//	@Description	Revokes a personal access token of the user
+//	@Tags			users
+//	@Param			tokenID	path		int		true	"Token ID"
+//	@Success		204		{string}	string	"Token revoked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *application) deletePersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	user := app.getUserFromContext(r)
+	if err := app.store.PersonalTokens.Delete(r.Context(), tokenID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.noContentResponse(w)
+}
+
+// getPersonalTokenFromContext returns the personal token the request was authenticated with,
+// nil when it was authenticated with an access token.
+func (app *application) getPersonalTokenFromContext(r *http.Request) *store.PersonalToken {
+	personalToken, _ := r.Context().Value(personalTokenContextKey).(*store.PersonalToken)
+	return personalToken
+}
@@ -14,6 +14,9 @@ type postKey string
 
 const postCtx postKey = "post"
 
+// firstCommentsPageLimit is the number of comments returned with a post
+const firstCommentsPageLimit = 20
+
 type createPostRequest struct {
 	Title   string   `json:"title" validate:"required,max=100"`
 	Content string   `json:"content" validate:"required,max=10000"`
@@ -44,6 +47,8 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.fanOutPost(r.Context(), &post)
+	app.publishPost(r.Context(), &post, loggedUser)
 
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -52,12 +57,25 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := app.getPostFromContext(r)
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	user := app.getUserFromContext(r)
+	// the rest of the comments are paged through with getCommentsHandler
+	comments, next, err := app.store.Comments.GetPage(r.Context(), post.ID, nil, user.ID, &store.PaginatedCommentQuery{Limit: firstCommentsPageLimit})
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 	post.Comments = comments
+	if next != nil {
+		post.CommentsNextCursor = next.Encode()
+	}
+	if post.CommentsCount, err = app.store.Comments.CountByPostID(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if post.ReactionSummary, err = app.store.Reactions.GetSummary(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -147,11 +165,58 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
+		visible, err := app.canViewPost(r, post)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			// posts of private accounts are hidden rather than forbidden to not reveal that they exist
+			app.resourceNotFound(w, r, store.ErrNotFound)
+			return
+		}
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// canViewPost reports whether the current user can see the post. Posts are hidden from users in a block
+// with the author, and posts of private accounts are visible only to the author, accepted followers
+// and moderators.
+func (app *application) canViewPost(r *http.Request, post *store.Post) (bool, error) {
+	user := app.getUserFromContext(r)
+	if post.UserID == user.ID {
+		return true, nil
+	}
+	blocked, err := app.store.Blocks.IsBlocked(r.Context(), user.ID, post.UserID)
+	if err != nil || blocked {
+		return false, err
+	}
+	author, err := app.getUser(r.Context(), post.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// the author deleted the account, the post stays like the rest of their anonymized content
+			return true, nil
+		}
+		return false, err
+	}
+	if !author.IsPrivate {
+		return true, nil
+	}
+	following, err := app.store.Followers.IsFollowing(r.Context(), author.ID, user.ID)
+	if err != nil || following {
+		return following, err
+	}
+	if app.getPersonalTokenFromContext(r) != nil {
+		return false, nil
+	}
+	role, err := app.store.Roles.GetByName(r.Context(), "moderator")
+	if err != nil {
+		return false, err
+	}
+	return user.Role.Level >= role.Level, nil
+}
+
 func (app *application) getPostFromContext(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtx).(*store.Post)
 	return post
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetPostVisibility(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	personalToken := personalTokenPrefix + "user2"
+	mockPersonalTokenStore := app.store.PersonalTokens.(*store.MockPersonalTokenStore)
+	mockPersonalTokenStore.On("Use", mock.Anything, hashToken(personalToken)).Return(
+		&store.PersonalToken{ID: 1, UserID: 2, Scopes: []string{store.ScopeFeedRead}}, nil)
+
+	mockUserStore := app.store.Users.(*store.MockUserStore)
+	mockUserStore.On("GetByID", mock.Anything, int64(4)).Return(&store.User{ID: 4, IsPrivate: true}, nil)
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetByID", mock.Anything, int64(10)).Return(&store.Post{ID: 10, UserID: 4}, nil)
+	mockPostStore.On("GetByID", mock.Anything, int64(20)).Return(&store.Post{ID: 20, UserID: 2}, nil)
+	mockPostStore.On("GetByID", mock.Anything, int64(30)).Return(&store.Post{ID: 30, UserID: 3}, nil)
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*store.Comment{}, nil, nil)
+	mockCommentStore.On("CountByPostID", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	mockReactionStore := app.store.Reactions.(*store.MockReactionStore)
+	mockReactionStore.On("GetSummary", mock.Anything, mock.Anything, mock.Anything).Return(&store.ReactionSummary{}, nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("IsBlocked", mock.Anything, int64(2), int64(3)).Return(true, nil)
+	mockBlockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("IsFollowing", mock.Anything, int64(4), int64(1)).Return(false, nil)
+
+	tests := []struct {
+		name           string
+		path           string
+		authorization  string
+		following      bool
+		expectedStatus int
+	}{
+		{
+			name:           "should show a public post",
+			path:           "/v1/posts/20",
+			authorization:  personalToken,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should hide a post from users in a block with the author",
+			path:           "/v1/posts/30",
+			authorization:  personalToken,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should hide a private post from users who do not follow the author",
+			path:           "/v1/posts/10",
+			authorization:  personalToken,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should show a private post to accepted followers",
+			path:           "/v1/posts/10",
+			authorization:  personalToken,
+			following:      true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should show a private post to moderators",
+			path:           "/v1/posts/10",
+			authorization:  token,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			call := mockFollowerStore.On("IsFollowing", mock.Anything, int64(4), int64(2)).Return(tc.following, nil)
+			defer call.Unset()
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.authorization)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestCreateCommentOnPostOfBlocker(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetByID", mock.Anything, int64(10)).Return(&store.Post{ID: 10, UserID: 2}, nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("IsBlocked", mock.Anything, int64(1), int64(2)).Return(false, nil)
+	// the block is created between loading the post and saving the comment
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("Create", mock.Anything, mock.Anything).Return(store.ErrBlocked)
+
+	req, err := http.NewRequest("POST", "/v1/posts/10/comments", strings.NewReader(`{"content": "Hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusForbidden, rr.Code)
+	checkResponseBody(t, map[string]any{"error": store.ErrBlocked.Error()}, rr.Body.Bytes())
+}
+
+func TestPostReactions(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetByID", mock.Anything, int64(10)).Return(&store.Post{ID: 10, UserID: 2}, nil)
+	mockPostStore.On("GetByID", mock.Anything, int64(20)).Return(&store.Post{ID: 20, UserID: 3}, nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*store.Comment{}, nil, nil)
+	mockCommentStore.On("CountByPostID", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	love := store.ReactionLove
+	mockReactionStore := app.store.Reactions.(*store.MockReactionStore)
+	mockReactionStore.On("Set", mock.Anything, int64(10), int64(1), store.ReactionLove).Return(nil)
+	// the block is created between loading the post and saving the reaction
+	mockReactionStore.On("Set", mock.Anything, int64(20), int64(1), mock.Anything).Return(store.ErrBlocked)
+	mockReactionStore.On("Delete", mock.Anything, int64(10), int64(1)).Return(nil)
+	mockReactionStore.On("Delete", mock.Anything, int64(20), int64(1)).Return(store.ErrNotFound)
+	mockReactionStore.On("GetSummary", mock.Anything, int64(10), int64(1)).Return(
+		&store.ReactionSummary{Reactions: map[string]int{store.ReactionLike: 2, store.ReactionLove: 1}, MyReaction: &love}, nil)
+
+	summary := map[string]any{
+		"reactions":   map[string]any{"like": float64(2), "love": float64(1)},
+		"my_reaction": "love",
+	}
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should react to a post and return its reactions",
+			method:         "PUT",
+			path:           "/v1/posts/10/reactions",
+			body:           `{"reaction": "love"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]any{"data": summary},
+		},
+		{
+			name:           "should reject an unknown reaction",
+			method:         "PUT",
+			path:           "/v1/posts/10/reactions",
+			body:           `{"reaction": "meh"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should forbid reacting to a post of a blocker",
+			method:         "PUT",
+			path:           "/v1/posts/20/reactions",
+			body:           `{"reaction": "like"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "should remove the reaction",
+			method:         "DELETE",
+			path:           "/v1/posts/10/reactions",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "should not remove a missing reaction",
+			method:         "DELETE",
+			path:           "/v1/posts/20/reactions",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedBody != nil {
+				checkResponseBody(t, tc.expectedBody, rr.Body.Bytes())
+			}
+		})
+	}
+
+	t.Run("should return the reactions with the post", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/posts/10", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		var response struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, summary["reactions"], response.Data["reactions"])
+		assert.Equal(t, "love", response.Data["my_reaction"])
+	})
+}
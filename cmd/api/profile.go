@@ -0,0 +1,339 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/mailer"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/google/uuid"
+)
+
+var (
+	errEmailTaken             = errors.New("email is already taken")
+	errInvalidCurrentPassword = errors.New("current password is invalid")
+)
+
+// getCurrentUserHandler godoc
+//
+//	@Summary		Fetches the current user
+//	@Description	Fetches the profile of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	UserProfile
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *application) getCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.userProfileResponse(w, r, app.getUserFromContext(r))
+}
+
+type UpdateCurrentUserPayload struct {
+	Username        *string `json:"username" validate:"omitempty,min=3,max=100"`
+	DisplayName     *string `json:"display_name" validate:"omitempty,max=100"`
+	Bio             *string `json:"bio" validate:"omitempty,max=1000"`
+	AvatarURL       *string `json:"avatar_url" validate:"omitempty,len=0|url,max=2048"`
+	IsPrivate       *bool   `json:"is_private"`
+	Email           *string `json:"email" validate:"omitempty,email"`
+	Password        *string `json:"password" validate:"omitempty,min=8,max=100"`
+	CurrentPassword *string `json:"current_password" validate:"required_with=Password,omitempty,max=100"`
+}
+
+// updateCurrentUserHandler godoc
+//
+//	@Summary		Updates the current user
+//	@Description	Updates the profile of the authenticated user. Making a private account public accepts
+//	@Description	the pending follow requests. A new email is applied once it is confirmed
+//	@Description	through the emailed activation link. Changing the password requires the current one and signs
+//	@Description	out every session
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateCurrentUserPayload	true	"Profile fields to change"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateCurrentUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	current := app.getUserFromContext(r)
+	user := *current
+
+	if payload.Password != nil {
+		// the user from the context does not carry the password hash
+		stored, err := app.store.Users.GetByEmail(ctx, current.Email)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if err := stored.Password.Compare(*payload.CurrentPassword); err != nil {
+			app.resourceForbiddenError(w, r, errInvalidCurrentPassword)
+			return
+		}
+		if err := user.Password.Set(*payload.Password); err != nil {
+			app.badRequestError(w, r, err)
+			return
+		}
+	}
+
+	// the new email is checked before anything is saved, so a taken email changes nothing
+	changeEmail := payload.Email != nil && normalizeEmail(*payload.Email) != normalizeEmail(user.Email)
+	if changeEmail {
+		_, err := app.store.Users.GetByEmail(ctx, *payload.Email)
+		switch {
+		case err == nil:
+			app.conflictError(w, r, errEmailTaken)
+			return
+		case !errors.Is(err, store.ErrNotFound):
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+	// the profile and the password are saved together
+	accepted, err := app.store.Users.Update(ctx, &user)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, fmt.Errorf("username is already taken"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.invalidateCachedUsers(ctx, user.ID)
+	// the accepted followers now see the posts of the user
+	app.invalidateTimelines(ctx, accepted...)
+
+	if payload.Password != nil {
+		if err := app.revokeUserSessions(ctx, user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		app.logger.Infow("Password changed", "userID", user.ID)
+	}
+
+	if changeEmail {
+		if err := app.requestEmailChange(r, &user, *payload.Email); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, &user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// requestEmailChange sends an invitation to the new email. The email of the user
+// is replaced only when the invitation is accepted through activateUserHandler.
+func (app *application) requestEmailChange(r *http.Request, user *store.User, newEmail string) error {
+	plainInviteCode := uuid.New().String()
+	if err := app.store.Users.RequestEmailChange(r.Context(), user.ID, newEmail, hashToken(plainInviteCode), app.config.mail.exp); err != nil {
+		return err
+	}
+
+	isProdEnv := app.config.env == "prodaction"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: app.config.frontednURL + "/activate?code=" + plainInviteCode,
+	}
+	if err := app.mailer.Send(mailer.EmailChangeTemplate, user.Username, newEmail, vars, !isProdEnv); err != nil {
+		app.logger.Errorw("unable to send email", "error", err)
+		return err
+	}
+	app.logger.Infow("Email change requested", "userID", user.ID)
+	return nil
+}
+
+// deleteCurrentUserHandler godoc
+//
+//	@Summary		Deletes the current user
+//	@Description	Deletes the account of the authenticated user and signs out every session. Posts and comments
+//	@Description	are anonymized or deleted depending on the configured policy
+//	@Tags			users
+//	@Success		204	{string}	string	"User deleted"
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := app.getUserFromContext(r)
+
+	if err := app.store.Users.Delete(ctx, user.ID, app.config.account.deletionPolicy); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.tokenDenylist().RevokeUser(ctx, user.ID, time.Now().Add(app.config.auth.tokenCfg.exp)); err != nil {
+		app.logger.Errorw("Failed to revoke access tokens of deleted user", "userID", user.ID, "error", err)
+	}
+	app.invalidateCachedUsers(ctx, user.ID)
+	app.logger.Infow("User deleted", "userID", user.ID, "policy", app.config.account.deletionPolicy)
+
+	app.noContentResponse(w)
+}
+
+// UserExport is the archive of everything stored about a user.
+type UserExport struct {
+	Profile   *store.User         `json:"profile"`
+	Posts     []*store.Post       `json:"posts"`
+	Comments  []*store.Comment    `json:"comments"`
+	Followers []*store.FollowUser `json:"followers"`
+	Following []*store.FollowUser `json:"following"`
+}
+
+// exportCurrentUserHandler godoc
+//
+//	@Summary		Exports the current user data
+//	@Description	Exports the profile, posts, comments, followers and following of the authenticated user
+//	@Description	as a JSON document, or as a zip archive of JSON files with format=zip
+//	@Tags			users
+//	@Produce		json
+//	@Produce		application/zip
+//	@Param			format	query		string	false	"json or zip"
+//	@Success		200		{object}	UserExport
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [get]
+func (app *application) exportCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		app.badRequestError(w, r, fmt.Errorf("unsupported export format %q", format))
+		return
+	}
+
+	ctx := r.Context()
+	user := app.getUserFromContext(r)
+	export := &UserExport{Profile: user}
+	var err error
+	if export.Posts, err = app.store.Posts.GetByUserID(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if export.Comments, err = app.store.Comments.GetByUserID(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if export.Followers, err = allFollowUsers(ctx, user.ID, app.store.Followers.GetFollowers); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if export.Following, err = allFollowUsers(ctx, user.ID, app.store.Followers.GetFollowing); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("gophersocial-export-%d", user.ID)
+	if format == "json" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		if err := writeJSON(w, http.StatusOK, export); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"posts.json", export.Posts},
+		{"comments.json", export.Comments},
+		{"followers.json", export.Followers},
+		{"following.json", export.Following},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		app.logger.Errorw("Failed to write export", "userID", user.ID, "error", err)
+	}
+}
+
+// allFollowUsers walks every page of a followers or following list.
+func allFollowUsers(ctx context.Context, userID int64, fetch followUsersFetcher) ([]*store.FollowUser, error) {
+	users := []*store.FollowUser{}
+	query := &store.PaginatedCursorQuery{Limit: 100}
+	for {
+		page, next, err := fetch(ctx, userID, userID, query)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, page...)
+		if next == nil {
+			return users, nil
+		}
+		query.Cursor = next
+	}
+}
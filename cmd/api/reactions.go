@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/NikolayProkopchuk/social/internal/store"
+)
+
+type ReactionPayload struct {
+	Reaction string `json:"reaction" validate:"required,oneof=like love haha wow sad angry"`
+}
+
+// setReactionHandler godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Sets the reaction of the current user to a post, replacing the previous one. The reaction is
+//	@Description	one of like, love, haha, wow, sad and angry
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		ReactionPayload	true	"Reaction"
+//	@Success		200		{object}	store.ReactionSummary
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [put]
+func (app *application) setReactionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserFromContext(r)
+	post := app.getPostFromContext(r)
+	var payload ReactionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	if err := app.store.Reactions.Set(r.Context(), post.ID, user.ID, payload.Reaction); err != nil {
+		switch {
+		case errors.Is(err, store.ErrBlocked):
+			app.resourceForbiddenError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	summary, err := app.store.Reactions.GetSummary(r.Context(), post.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, summary); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteReactionHandler godoc
+//
+//	@Summary		Removes the reaction to a post
+//	@Description	Removes the reaction of the current user to a post
+//	@Tags			posts
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Reaction removed"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [delete]
+func (app *application) deleteReactionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserFromContext(r)
+	post := app.getPostFromContext(r)
+	if err := app.store.Reactions.Delete(r.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.noContentResponse(w)
+}
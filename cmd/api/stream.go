@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/events"
+	"github.com/NikolayProkopchuk/social/internal/store"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies and detects gone clients. The credentials
+// of the stream are checked again on every heartbeat.
+var streamHeartbeat = 15 * time.Second
+
+const (
+	// streamWriteTimeout replaces the write timeout of the server, which would end every stream after it
+	streamWriteTimeout = 10 * time.Second
+	// streamRetry tells clients how long to wait before reconnecting, in milliseconds
+	streamRetry = 3000
+)
+
+// feedStreamHandler godoc
+//
+//	@Summary		Streams feed updates
+//	@Description	Streams the new posts of the followed users and the new comments on the posts of the current
+//	@Description	user as Server-Sent Events of the types post and comment. The stream stays open until
+//	@Description	the client disconnects, the token expires or is revoked, or the user is deactivated
+//	@Tags			feed
+//	@Produce		text/event-stream
+//	@Success		200	{object}	events.Event
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/feed/stream [get]
+func (app *application) feedStreamHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserFromContext(r)
+	ctx := r.Context()
+	if expiresAt, ok := app.credentialsExpiry(r); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, expiresAt)
+		defer cancel()
+	}
+	subscription, err := app.events.Subscribe(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := writeStreamFrame(w, rc, fmt.Sprintf("retry: %d\n\n", streamRetry)); err != nil {
+		return
+	}
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var frame string
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-subscription:
+			if !ok {
+				return
+			}
+			frame = fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, event.Data)
+		case <-heartbeat.C:
+			if err := app.checkStreamCredentials(ctx, r, user.ID); err != nil {
+				app.logger.Infow("Feed stream closed", "userID", user.ID, "error", err)
+				return
+			}
+			frame = ": heartbeat\n\n"
+		}
+		if err := writeStreamFrame(w, rc, frame); err != nil {
+			app.logger.Infow("Feed stream closed", "userID", user.ID, "error", err)
+			return
+		}
+	}
+}
+
+// credentialsExpiry returns when the token the request is authenticated with expires, if it does.
+func (app *application) credentialsExpiry(r *http.Request) (time.Time, bool) {
+	if claims := app.getClaimsFromContext(r); claims != nil {
+		expiresAt, err := claims.GetExpirationTime()
+		if err != nil || expiresAt == nil {
+			return time.Time{}, false
+		}
+		return expiresAt.Time, true
+	}
+	if personalToken := app.getPersonalTokenFromContext(r); personalToken != nil && personalToken.ExpiresAt != nil {
+		return *personalToken.ExpiresAt, true
+	}
+	return time.Time{}, false
+}
+
+// checkStreamCredentials tells whether the token of a long-lived request has been revoked or
+// the user deactivated since the request was authenticated.
+func (app *application) checkStreamCredentials(ctx context.Context, r *http.Request, userID int64) error {
+	if claims := app.getClaimsFromContext(r); claims != nil {
+		jti, _ := claims["jti"].(string)
+		issuedAt, err := issuedAtFromClaims(claims)
+		if err != nil {
+			return err
+		}
+		revoked, err := app.tokenDenylist().IsRevoked(ctx, jti, userID, issuedAt)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return fmt.Errorf("token has been revoked")
+		}
+	} else if personalToken := app.getPersonalTokenFromContext(r); personalToken != nil {
+		// a deleted token is not found anymore
+		if _, err := app.store.PersonalTokens.Use(ctx, personalToken.TokenHash); err != nil {
+			return err
+		}
+	}
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.Active {
+		return errUserNotActivated
+	}
+	return nil
+}
+
+func writeStreamFrame(w http.ResponseWriter, rc *http.ResponseController, frame string) error {
+	if err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := fmt.Fprint(w, frame); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// publishPost notifies the followers of the author about the new post, except those who have muted the author.
+func (app *application) publishPost(ctx context.Context, post *store.Post, author *store.User) {
+	followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Errorw("Failed to get followers for the post event", "postID", post.ID, "error", err)
+		return
+	}
+	muterIDs, err := app.store.Mutes.GetMuterIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Errorw("Failed to get muters for the post event", "postID", post.ID, "error", err)
+		return
+	}
+	followerIDs = slices.DeleteFunc(followerIDs, func(id int64) bool {
+		return slices.Contains(muterIDs, id)
+	})
+	app.publish(ctx, events.TypePost, &store.PostWithMetadata{
+		ID:             post.ID,
+		Content:        post.Content,
+		Title:          post.Title,
+		UserID:         int(post.UserID),
+		Tags:           post.Tags,
+		CreatedAt:      post.CreatedAt,
+		LastActivityAt: post.CreatedAt,
+		Author: &store.PostAuthor{
+			ID:          author.ID,
+			Username:    author.Username,
+			DisplayName: author.DisplayName,
+			AvatarURL:   author.AvatarURL,
+		},
+	}, followerIDs...)
+}
+
+// publishComment notifies the author of the post about a comment of another user.
+func (app *application) publishComment(ctx context.Context, post *store.Post, comment *store.Comment) {
+	if comment.User.ID == post.UserID {
+		return
+	}
+	app.publish(ctx, events.TypeComment, comment, post.UserID)
+}
+
+func (app *application) publish(ctx context.Context, eventType string, data any, userIDs ...int64) {
+	if len(userIDs) == 0 {
+		return
+	}
+	event, err := events.NewEvent(eventType, data)
+	if err != nil {
+		app.logger.Errorw("Failed to encode event", "type", eventType, "error", err)
+		return
+	}
+	if err := app.events.Publish(ctx, event, userIDs...); err != nil {
+		app.logger.Errorw("Failed to publish event", "type", eventType, "error", err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFeedStream(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	server := httptest.NewServer(app.mount())
+	defer server.Close()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	personalToken := personalTokenPrefix + "user2"
+	mockPersonalTokenStore := app.store.PersonalTokens.(*store.MockPersonalTokenStore)
+	mockPersonalTokenStore.On("Use", mock.Anything, hashToken(personalToken)).Return(
+		&store.PersonalToken{ID: 1, UserID: 2, Scopes: []string{store.ScopeFeedRead}}, nil)
+
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*store.Post).ID = 10
+	}).Return(nil)
+	mockPostStore.On("GetByID", mock.Anything, int64(20)).Return(&store.Post{ID: 20, UserID: 2}, nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("GetFollowerIDs", mock.Anything, int64(1)).Return([]int64{2, 3}, nil)
+	mockMuteStore := app.store.Mutes.(*store.MockMuteStore)
+	mockMuteStore.On("GetMuterIDs", mock.Anything, int64(1)).Return([]int64{3}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/v1/users/feed/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+personalToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkResponseCode(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	stream := bufio.NewReader(resp.Body)
+	readFrame := func() string {
+		t.Helper()
+		var frame strings.Builder
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				return frame.String()
+			}
+			frame.WriteString(line)
+		}
+	}
+	// the stream is subscribed once the retry interval is sent
+	assert.Equal(t, "retry: 3000\n", readFrame())
+
+	send := func(method, path, body string) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		checkResponseCode(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	t.Run("should stream the new post of a followed user", func(t *testing.T) {
+		send("POST", "/v1/posts", `{"title": "Title", "content": "Content"}`)
+		frame := readFrame()
+		assert.True(t, strings.HasPrefix(frame, "event: post\ndata: "), frame)
+		assert.Contains(t, frame, `"id":10`)
+		assert.Contains(t, frame, `"username":"TestModeratorUser"`)
+	})
+
+	t.Run("should stream a new comment on a post of the user", func(t *testing.T) {
+		send("POST", "/v1/posts/20/comments", `{"content": "Hello"}`)
+		frame := readFrame()
+		assert.True(t, strings.HasPrefix(frame, "event: comment\ndata: "), frame)
+		assert.Contains(t, frame, `"content":"Hello"`)
+	})
+}
+
+func TestFeedStreamCredentials(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	heartbeat := streamHeartbeat
+	streamHeartbeat = 50 * time.Millisecond
+	t.Cleanup(func() { streamHeartbeat = heartbeat })
+
+	// openStream connects to the stream and waits until it is subscribed
+	openStream := func(t *testing.T, app *application, exp time.Duration) *bufio.Reader {
+		t.Helper()
+		// the claims are checked, so a real authenticator is needed
+		app.authenticator = auth.NewJWTAuthenticator("secret", "gopher.social", "gopher.social")
+		token, err := app.authenticator.GenerateToken(jwt.MapClaims{
+			"sub": 2,
+			"exp": time.Now().Add(exp).Unix(),
+			"iat": time.Now().Unix(),
+			"nbf": time.Now().Unix(),
+			"iss": "gopher.social",
+			"aud": "gopher.social",
+			"jti": "stream",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewServer(app.mount())
+		t.Cleanup(server.Close)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+		req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/v1/users/feed/stream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		checkResponseCode(t, http.StatusOK, resp.StatusCode)
+		stream := bufio.NewReader(resp.Body)
+		if line, err := stream.ReadString('\n'); err != nil || line != "retry: 3000\n" {
+			t.Fatalf("expected the retry interval, got %q, %v", line, err)
+		}
+		return stream
+	}
+	// waitForClose reads the stream until the server closes it
+	waitForClose := func(t *testing.T, stream *bufio.Reader) {
+		t.Helper()
+		for {
+			if _, err := stream.ReadString('\n'); err != nil {
+				if err != io.EOF {
+					t.Fatalf("expected the server to close the stream, got %v", err)
+				}
+				return
+			}
+		}
+	}
+
+	t.Run("should close the stream when the token expires", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		stream := openStream(t, app, time.Second)
+		waitForClose(t, stream)
+	})
+
+	t.Run("should close the stream when the token is revoked", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+		mockRevokedTokenStore.ExpectedCalls = nil
+		mockRevokedTokenStore.On("IsRevoked", mock.Anything, "stream", int64(2), mock.Anything).Return(false, nil).Once()
+		mockRevokedTokenStore.On("IsRevoked", mock.Anything, "stream", int64(2), mock.Anything).Return(true, nil)
+		stream := openStream(t, app, time.Hour)
+		waitForClose(t, stream)
+	})
+
+	t.Run("should close the stream when the user is deactivated", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.ExpectedCalls = nil
+		mockUserStore.On("GetByID", mock.Anything, int64(2)).Return(&store.User{ID: 2, Active: true}, nil).Once()
+		mockUserStore.On("GetByID", mock.Anything, int64(2)).Return(&store.User{ID: 2, Active: false}, nil)
+		stream := openStream(t, app, time.Hour)
+		waitForClose(t, stream)
+	})
+}
@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/NikolayProkopchuk/social/internal/auth"
+	"github.com/NikolayProkopchuk/social/internal/events"
+	"github.com/NikolayProkopchuk/social/internal/mailer"
 	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/NikolayProkopchuk/social/internal/store/cache"
@@ -24,18 +26,21 @@ func newTestApp(t *testing.T, config config) *application {
 		ID:       1,
 		Username: "TestModeratorUser",
 		Email:    "test.moderator@mail.com",
+		Active:   true,
 		Role:     store.Role{ID: 2, Name: "moderator", Description: "Moderator", Level: 50},
 	}
 	user1 := store.User{
 		ID:       2,
 		Username: "TestUser",
 		Email:    "test@mail.com",
+		Active:   true,
 		Role:     store.Role{ID: 3, Name: "user", Description: "Regular User", Level: 10},
 	}
 	user3 := store.User{
 		ID:       3,
 		Username: "TestUser3",
 		Email:    "test3@mail.com",
+		Active:   true,
 		Role:     store.Role{ID: 3, Name: "user", Description: "Regular User", Level: 10},
 	}
 
@@ -47,12 +52,22 @@ func newTestApp(t *testing.T, config config) *application {
 
 	mockUserCache.On("Set", mock.Anything, user3).Return(nil)
 
+	mockRevokedTokenCache := mockCache.RevokedTokens.(*cache.MockRevokedTokenCache)
+	mockRevokedTokenCache.On("IsRevoked", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
 	mockStore := store.NewMockStore()
 	mockUserStore := mockStore.Users.(*store.MockUserStore)
 	mockUserStore.On("GetByID", mock.Anything, int64(1)).Return(&moderatorUser, nil)
 	mockUserStore.On("GetByID", mock.Anything, int64(2)).Return(&user1, nil)
 	mockUserStore.On("GetByID", mock.Anything, int64(3)).Return(&user3, nil)
 
+	mockRevokedTokenStore := mockStore.RevokedTokens.(*store.MockRevokedTokenStore)
+	mockRevokedTokenStore.On("IsRevoked", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+	mockFollowerStore := mockStore.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("GetStats", mock.Anything, mock.Anything, mock.Anything).Return(
+		&store.FollowStats{FollowersCount: 1, FollowingCount: 2}, nil).Maybe()
+
 	mockRoleStore := mockStore.Roles.(*store.MockRoleStore)
 	mockRoleStore.On("GetByName", mock.Anything, "moderator").Return(
 		&store.Role{ID: 2, Name: "moderator", Description: "Moderator", Level: 50}, nil)
@@ -63,6 +78,8 @@ func newTestApp(t *testing.T, config config) *application {
 		cache:         mockCache,
 		authenticator: auth.NewMockAuthenticator(),
 		config:        config,
+		events:        events.NewMemoryBroker(),
+		mailer:        &mailer.MockClient{},
 		rateLimiter:   ratelimiter.NewFixedWindowRateLimiter(config.rateLimiter.RequestsPerTimeFrame, config.rateLimiter.TimeFrame),
 	}
 
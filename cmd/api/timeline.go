@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/NikolayProkopchuk/social/internal/store"
+)
+
+// fanOutPost pushes the post to the timelines of the followers of its author, or marks the author
+// as a celebrity whose posts are read from the follows when the author has too many followers.
+// Failures are only logged: the timelines of the followers are dropped so that their feeds fall back
+// to the follows instead of missing the post.
+func (app *application) fanOutPost(ctx context.Context, post *store.Post) {
+	if !app.config.redis.enabled {
+		return
+	}
+	stats, err := app.store.Followers.GetStats(ctx, post.UserID, post.UserID)
+	if err != nil {
+		app.logger.Errorw("Failed to count followers for timelines", "postID", post.ID, "error", err)
+		return
+	}
+	if stats.FollowersCount >= app.config.timeline.celebrityFollowers {
+		if err := app.cache.Timelines.AddCelebrity(ctx, post.UserID); err != nil {
+			app.logger.Errorw("Failed to mark celebrity for timelines", "userID", post.UserID, "error", err)
+		}
+		return
+	}
+	followerIDs, err := app.store.Followers.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		app.logger.Errorw("Failed to get followers for timelines", "postID", post.ID, "error", err)
+		return
+	}
+	if err := app.cache.Timelines.Push(ctx, post, followerIDs, app.config.timeline.maxLength); err != nil {
+		app.logger.Errorw("Failed to push post to timelines", "postID", post.ID, "error", err)
+		app.invalidateTimelines(ctx, followerIDs...)
+	}
+}
+
+// feedTimeline returns the window of the timeline of the user for the page, nil when the page has to be
+// read from the follows.
+func (app *application) feedTimeline(ctx context.Context, userID int64, paginatedFeedQuery *store.PaginatedFeedQuery) *store.FeedTimeline {
+	if !app.config.redis.enabled || !paginatedFeedQuery.TimelineEligible() {
+		return nil
+	}
+	// one extra post tells whether there is a next page, as in the feed query
+	timeline, err := app.cache.Timelines.Get(ctx, userID, paginatedFeedQuery.Cursor, paginatedFeedQuery.Limit+1)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			app.logger.Errorw("Failed to get timeline", "userID", userID, "error", err)
+		}
+		return nil
+	}
+	return timeline
+}
+
+// invalidateTimelines drops the timelines of the users after the set of users they follow changes.
+func (app *application) invalidateTimelines(ctx context.Context, userIDs ...int64) {
+	if !app.config.redis.enabled || len(userIDs) == 0 {
+		return
+	}
+	if err := app.cache.Timelines.Delete(ctx, userIDs...); err != nil {
+		app.logger.Errorw("Failed to invalidate timelines", "userIDs", userIDs, "error", err)
+	}
+}
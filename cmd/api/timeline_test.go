@@ -0,0 +1,212 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/ratelimiter"
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/NikolayProkopchuk/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostFanOut(t *testing.T) {
+	newApp := func(t *testing.T, celebrityFollowers int) *application {
+		cfg := config{
+			redis: &redisConfig{
+				enabled: true,
+			},
+			rateLimiter: &ratelimiter.Config{
+				Enabled: false,
+			},
+			timeline: &timelineConfig{
+				maxLength:          100,
+				celebrityFollowers: celebrityFollowers,
+			},
+		}
+		app := newTestApp(t, cfg)
+		mockPostStore := app.store.Posts.(*store.MockPostStore)
+		mockPostStore.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+		mockFollowerStore.On("GetFollowerIDs", mock.Anything, int64(1)).Return([]int64{2, 3}, nil)
+		mockMuteStore := app.store.Mutes.(*store.MockMuteStore)
+		mockMuteStore.On("GetMuterIDs", mock.Anything, int64(1)).Return([]int64{}, nil)
+		return app
+	}
+	createPost := func(t *testing.T, app *application) {
+		t.Helper()
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("POST", "/v1/posts", strings.NewReader(`{"title": "Title", "content": "Content"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := executeRequest(req, app.mount())
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+	}
+
+	t.Run("should push the post to the timelines of the followers", func(t *testing.T) {
+		app := newApp(t, 10)
+		mockTimelineCache := app.cache.Timelines.(*cache.MockTimelineCache)
+		mockTimelineCache.On("Push", mock.Anything, mock.Anything, []int64{2, 3}, 100).Return(nil)
+
+		createPost(t, app)
+
+		mockTimelineCache.AssertExpectations(t)
+		mockTimelineCache.AssertNotCalled(t, "AddCelebrity", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should mark the author as a celebrity instead of pushing the post", func(t *testing.T) {
+		// the author has one follower
+		app := newApp(t, 1)
+		mockTimelineCache := app.cache.Timelines.(*cache.MockTimelineCache)
+		mockTimelineCache.On("AddCelebrity", mock.Anything, int64(1)).Return(nil)
+
+		createPost(t, app)
+
+		mockTimelineCache.AssertExpectations(t)
+		mockTimelineCache.AssertNotCalled(t, "Push", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should drop the timelines when the push fails", func(t *testing.T) {
+		app := newApp(t, 10)
+		mockTimelineCache := app.cache.Timelines.(*cache.MockTimelineCache)
+		mockTimelineCache.On("Push", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(store.ErrConflict)
+		mockTimelineCache.On("Delete", mock.Anything, []int64{2, 3}).Return(nil)
+
+		createPost(t, app)
+
+		mockTimelineCache.AssertExpectations(t)
+	})
+}
+
+func TestGetUserFeedFromTimeline(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: true,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		timeline: &timelineConfig{
+			maxLength:          100,
+			celebrityFollowers: 10,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeline := &store.FeedTimeline{PostIDs: []int64{7, 5}, Oldest: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), CelebrityIDs: []int64{3}}
+	mockTimelineCache := app.cache.Timelines.(*cache.MockTimelineCache)
+	mockTimelineCache.On("Get", mock.Anything, int64(1), (*store.Cursor)(nil), 11).Return(timeline, nil)
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetUserFeed", mock.Anything, mock.Anything, mock.MatchedBy(func(q *store.PaginatedFeedQuery) bool {
+		return q.Timeline == timeline
+	})).Return([]*store.PostWithMetadata{}, (*store.Cursor)(nil), nil).Once()
+	mockPostStore.On("GetUserFeed", mock.Anything, mock.Anything, mock.MatchedBy(func(q *store.PaginatedFeedQuery) bool {
+		return q.Timeline == nil
+	})).Return([]*store.PostWithMetadata{}, (*store.Cursor)(nil), nil).Once()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		// the timeline holds the newest posts
+		{name: "newest first", query: "?sort=desc"},
+		{name: "oldest first", query: "?sort=asc"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/v1/users/feed"+tc.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusOK, rr.Code)
+		})
+	}
+	mockTimelineCache.AssertNumberOfCalls(t, "Get", 1)
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestTimelineInvalidation(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: true,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		timeline: &timelineConfig{
+			maxLength:          100,
+			celebrityFollowers: 10,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("Follow", mock.Anything, mock.Anything, mock.Anything).Return(store.FollowStatusAccepted, nil)
+	mockFollowerStore.On("Unfollow", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockFollowerStore.On("AcceptFollowRequest", mock.Anything, int64(1), int64(3)).Return(nil)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("Block", mock.Anything, int64(1), int64(2)).Return(nil)
+	mockTimelineCache := app.cache.Timelines.(*cache.MockTimelineCache)
+	mockTimelineCache.On("Delete", mock.Anything, mock.Anything).Return(nil)
+
+	tests := []struct {
+		method          string
+		path            string
+		expectedDeleted []int64
+	}{
+		{method: "PUT", path: "/v1/users/2/follow", expectedDeleted: []int64{1}},
+		{method: "PUT", path: "/v1/users/2/unfollow", expectedDeleted: []int64{1}},
+		{method: "PUT", path: "/v1/users/me/follow-requests/3", expectedDeleted: []int64{3}},
+		{method: "PUT", path: "/v1/users/2/block", expectedDeleted: []int64{1, 2}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusNoContent, rr.Code)
+			mockTimelineCache.AssertCalled(t, "Delete", mock.Anything, tc.expectedDeleted)
+		})
+	}
+	t.Run("PATCH /v1/users/me", func(t *testing.T) {
+		// making the account public accepts the pending follow requests
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("Update", mock.Anything, mock.Anything).Return([]int64{2, 3}, nil)
+		mockUserCache := app.cache.Users.(*cache.MockUserCache)
+		mockUserCache.On("Delete", mock.Anything, []int64{1}).Return(nil)
+
+		req, err := http.NewRequest("PATCH", "/v1/users/me", strings.NewReader(`{"is_private": false}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		mockTimelineCache.AssertCalled(t, "Delete", mock.Anything, []int64{2, 3})
+	})
+}
@@ -1,14 +1,14 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 // ActivateUser godoc
@@ -20,6 +20,7 @@ import (
 //	@Param			token	path		string	true	"Invitation token"
 //	@Success		204		{string}	string	"User activated"
 //	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/active [put]
@@ -34,18 +35,21 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	hash := sha256.Sum256([]byte(payload.InviteCode))
-	inviteCodeHashed := hex.EncodeToString(hash[:])
+	inviteCodeHashed := hashToken(payload.InviteCode)
 
-	if err := app.store.Users.Activate(r.Context(), inviteCodeHashed); err != nil {
+	userID, err := app.store.Users.Activate(r.Context(), inviteCodeHashed)
+	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.resourceNotFound(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errEmailTaken)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
+	app.invalidateCachedUsers(r.Context(), userID)
 
 	if err := app.jsonResponse(w, http.StatusNoContent, ""); err != nil {
 		app.internalServerError(w, r, err)
@@ -56,6 +60,62 @@ type ActivateUserPayload struct {
 	InviteCode string `json:"token" validate:"required,uuid"`
 }
 
+// ResendActivation godoc
+//
+//	@Summary		Resends the activation email
+//	@Description	Replaces the invitation code of an inactive user and sends a new activation email
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"User email"
+//	@Success		202		{string}	string					"Activation email sent"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/users/activation/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// unknown and already activated accounts get the same response to not reveal registered emails
+	if user == nil || user.Active {
+		if err := app.jsonResponse(w, http.StatusAccepted, ""); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	plainInviteCode := uuid.New().String()
+	if err := app.store.Users.ReplaceInvitation(r.Context(), user.ID, hashToken(plainInviteCode), app.config.mail.exp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.sendInvitation(user, plainInviteCode); err != nil {
+		app.logger.Errorw("unable to send email", "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, ""); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 // getUserHandler godoc
 //
 //	@Summary		Fetches a user profile
@@ -64,7 +124,7 @@ type ActivateUserPayload struct {
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	UserProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -86,7 +146,22 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	app.userProfileResponse(w, r, user)
+}
+
+// UserProfile is a user with the follower counts as seen by the caller.
+type UserProfile struct {
+	*store.User
+	*store.FollowStats
+}
+
+func (app *application) userProfileResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	stats, err := app.store.Followers.GetStats(r.Context(), user.ID, app.getViewerID(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, &UserProfile{User: user, FollowStats: stats}); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -96,16 +171,105 @@ func (app *application) getUserFromContext(r *http.Request) *store.User {
 	return value.(*store.User)
 }
 
+// getViewerID returns the ID of the authenticated user, 0 for anonymous requests.
+func (app *application) getViewerID(r *http.Request) int64 {
+	user, ok := r.Context().Value(userContextKey).(*store.User)
+	if !ok {
+		return 0
+	}
+	return user.ID
+}
+
+// getUserFollowersHandler godoc
+//
+//	@Summary		Fetches the followers of a user
+//	@Description	Fetches a page of the users following the user, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the page"
+//	@Success		200		{object}	[]store.FollowUser
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getUserFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.followUsersResponse(w, r, app.store.Followers.GetFollowers)
+}
+
+// getUserFollowingHandler godoc
+//
+//	@Summary		Fetches the users a user follows
+//	@Description	Fetches a page of the users the user follows, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the page"
+//	@Success		200		{object}	[]store.FollowUser
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/users/{userID}/following [get]
+func (app *application) getUserFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.followUsersResponse(w, r, app.store.Followers.GetFollowing)
+}
+
+type followUsersFetcher func(ctx context.Context, userID, viewerID int64, query *store.PaginatedCursorQuery) ([]*store.FollowUser, *store.Cursor, error)
+
+func (app *application) followUsersResponse(w http.ResponseWriter, r *http.Request, fetch followUsersFetcher) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	paginatedQuery, err := store.ParsePaginatedCursorQuery(r)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if err := Validator.Struct(paginatedQuery); err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	if _, err := app.getUser(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	users, next, err := fetch(r.Context(), userID, app.getViewerID(r), paginatedQuery)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	if err := app.paginatedJSONResponse(w, http.StatusOK, users, nextCursor); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 // followUserHandler godoc
 //
 //	@Summary		Follwow a user
-//	@Description	Follow a user by ID
+//	@Description	Follow a user by ID. Following a private account sends a follow request the user
+//	@Description	has to approve
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int		true	"User ID"
+//	@Param			id	path		int	true	"User ID"
+//	@Success		202	{object}	FollowResponse
 //	@Success		204	{string}	string	"User followed successfully"
 //	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
@@ -130,30 +294,48 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 	//todo get authenticated user
 	userLoggedIn := app.getUserFromContext(r)
 
-	if err := app.store.Followers.Follow(r.Context(), followedUser, userLoggedIn); err != nil {
+	status, err := app.store.Followers.Follow(r.Context(), followedUser, userLoggedIn)
+	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrConflict):
 			app.conflictError(w, r, err)
+		case errors.Is(err, store.ErrSelfFollow):
+			app.badRequestError(w, r, err)
+		case errors.Is(err, store.ErrBlocked):
+			app.resourceForbiddenError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	if status == store.FollowStatusPending {
+		if err := app.jsonResponse(w, http.StatusAccepted, &FollowResponse{Status: status}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.invalidateTimelines(r.Context(), userLoggedIn.ID)
 	app.noContentResponse(w)
 }
 
+type FollowResponse struct {
+	Status string `json:"status"`
+}
+
 // UnfollowUser godoc
 //
 //	@Summary		Unfollows a user
-//	@Description	Unfollows a user by ID
+//	@Description	Unfollows a user by ID or withdraws the pending follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
 //	@Success		204		{string}	string	"User unfollowed"
 //	@Failure		400		{object}	error	"Bad request"
-//	@Failure		404		{object}	error	"User not found"
+//	@Failure		404		{object}	error	"User not found or not followed"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/unfollow [put]
 func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -176,6 +358,51 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	userLoggedIn := app.getUserFromContext(r)
 
 	if err := app.store.Followers.Unfollow(r.Context(), user, userLoggedIn); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.invalidateTimelines(r.Context(), userLoggedIn.ID)
+
+	app.noContentResponse(w)
+}
+
+// RevokeUserSessions godoc
+//
+//	@Summary		Revokes all sessions of a user
+//	@Description	Revokes every access and refresh token issued to the user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Sessions revoked"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/sessions [delete]
+func (app *application) revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, r, err)
+		return
+	}
+	user, err := app.store.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.resourceNotFound(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.revokeUserSessions(r.Context(), user.ID); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
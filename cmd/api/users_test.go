@@ -1,8 +1,12 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +14,7 @@ import (
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/NikolayProkopchuk/social/internal/store/cache"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -23,10 +28,17 @@ var claims = jwt.MapClaims{
 
 var expectedModeratorResponseBody = map[string]any{
 	"data": map[string]any{
-		"id":         float64(1),
-		"email":      "test.moderator@mail.com",
-		"username":   "TestModeratorUser",
-		"created_at": "0001-01-01T00:00:00Z",
+		"id":               float64(1),
+		"email":            "test.moderator@mail.com",
+		"username":         "TestModeratorUser",
+		"active":           true,
+		"mfa_enabled":      false,
+		"created_at":       "0001-01-01T00:00:00Z",
+		"followers_count":  float64(1),
+		"following_count":  float64(2),
+		"followed_by_me":   false,
+		"follow_requested": false,
+		"is_private":       false,
 		"role": map[string]any{
 			"id":          float64(2),
 			"name":        "moderator",
@@ -38,10 +50,17 @@ var expectedModeratorResponseBody = map[string]any{
 
 var expectedUser1ResponseBody = map[string]any{
 	"data": map[string]any{
-		"id":         float64(2),
-		"email":      "test@mail.com",
-		"username":   "TestUser",
-		"created_at": "0001-01-01T00:00:00Z",
+		"id":               float64(2),
+		"email":            "test@mail.com",
+		"username":         "TestUser",
+		"active":           true,
+		"mfa_enabled":      false,
+		"created_at":       "0001-01-01T00:00:00Z",
+		"followers_count":  float64(1),
+		"following_count":  float64(2),
+		"followed_by_me":   false,
+		"follow_requested": false,
+		"is_private":       false,
 		"role": map[string]any{
 			"id":          float64(3),
 			"name":        "user",
@@ -53,10 +72,17 @@ var expectedUser1ResponseBody = map[string]any{
 
 var expectedUser3ResponseBody = map[string]any{
 	"data": map[string]any{
-		"id":         float64(3),
-		"email":      "test3@mail.com",
-		"username":   "TestUser3",
-		"created_at": "0001-01-01T00:00:00Z",
+		"id":               float64(3),
+		"email":            "test3@mail.com",
+		"username":         "TestUser3",
+		"active":           true,
+		"mfa_enabled":      false,
+		"created_at":       "0001-01-01T00:00:00Z",
+		"followers_count":  float64(1),
+		"following_count":  float64(2),
+		"followed_by_me":   false,
+		"follow_requested": false,
+		"is_private":       false,
 		"role": map[string]any{
 			"id":          float64(3),
 			"name":        "user",
@@ -284,3 +310,637 @@ func TestGetUserStorageCalls(t *testing.T) {
 		mockRoleStore.AssertCalled(t, "GetByName", mock.Anything, "moderator")
 	})
 }
+
+func TestUpdateCurrentUser(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should require the current password to change the password",
+			body:           `{"password": "new-password"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should reject an invalid current password",
+			body:           `{"password": "new-password", "current_password": "wrong-password"}`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   map[string]any{"error": errInvalidCurrentPassword.Error()},
+		},
+		{
+			name:           "should reject a taken username",
+			body:           `{"username": "TestUser"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   map[string]any{"error": "username is already taken"},
+		},
+		{
+			name:           "should update the profile",
+			body:           `{"display_name": "Moderator", "bio": "Keeps things civil"}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestApp(t, cfg)
+			mux := app.mount()
+			token, err := app.authenticator.GenerateToken(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored := &store.User{ID: 1, Email: "test.moderator@mail.com"}
+			if err := stored.Password.Set("password"); err != nil {
+				t.Fatal(err)
+			}
+			mockUserStore := app.store.Users.(*store.MockUserStore)
+			mockUserStore.On("GetByEmail", mock.Anything, "test.moderator@mail.com").Return(stored, nil)
+			mockUserStore.On("Update", mock.Anything, mock.MatchedBy(func(u *store.User) bool {
+				return u.Username == "TestUser"
+			})).Return(nil, store.ErrConflict)
+			mockUserStore.On("Update", mock.Anything, mock.Anything).Return(nil, nil)
+
+			req, err := http.NewRequest("PATCH", "/v1/users/me", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedBody != nil {
+				checkResponseBody(t, tc.expectedBody, rr.Body.Bytes())
+			}
+			mockUserStore.AssertNotCalled(t, "Update", mock.Anything, mock.MatchedBy(func(u *store.User) bool {
+				return u.Password.Compare("new-password") == nil
+			}))
+		})
+	}
+
+	t.Run("should not save the profile when the new email is taken", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("GetByEmail", mock.Anything, "test@mail.com").Return(&store.User{ID: 2, Email: "test@mail.com"}, nil)
+
+		req, err := http.NewRequest("PATCH", "/v1/users/me", strings.NewReader(`{"display_name": "Moderator", "email": "test@mail.com"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusConflict, rr.Code)
+		checkResponseBody(t, map[string]any{"error": errEmailTaken.Error()}, rr.Body.Bytes())
+		mockUserStore.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should save the password with the profile", func(t *testing.T) {
+		cfg := cfg
+		cfg.auth = &authConfig{tokenCfg: tokenConfig{exp: time.Hour}}
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored := &store.User{ID: 1, Email: "test.moderator@mail.com"}
+		if err := stored.Password.Set("password"); err != nil {
+			t.Fatal(err)
+		}
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("GetByEmail", mock.Anything, "test.moderator@mail.com").Return(stored, nil)
+		mockUserStore.On("Update", mock.Anything, mock.MatchedBy(func(u *store.User) bool {
+			return u.DisplayName == "Moderator" && u.Password.Compare("new-password") == nil
+		})).Return(nil, nil)
+		mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+		mockRevokedTokenStore.On("RevokeUser", mock.Anything, int64(1), mock.Anything).Return(nil)
+		mockRefreshTokenStore := app.store.RefreshTokens.(*store.MockRefreshTokenStore)
+		mockRefreshTokenStore.On("RevokeAllForUser", mock.Anything, int64(1)).Return(nil)
+
+		body := `{"display_name": "Moderator", "password": "new-password", "current_password": "password"}`
+		req, err := http.NewRequest("PATCH", "/v1/users/me", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		mockUserStore.AssertNumberOfCalls(t, "Update", 1)
+		mockRefreshTokenStore.AssertCalled(t, "RevokeAllForUser", mock.Anything, int64(1))
+	})
+
+	t.Run("should return the new profile fields", func(t *testing.T) {
+		app := newTestApp(t, cfg)
+		mux := app.mount()
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mockUserStore := app.store.Users.(*store.MockUserStore)
+		mockUserStore.On("Update", mock.Anything, mock.Anything).Return(nil, nil)
+
+		req, err := http.NewRequest("PATCH", "/v1/users/me", strings.NewReader(`{"avatar_url": "https://cdn.example.com/a.png"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		var response struct {
+			Data store.User `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "https://cdn.example.com/a.png", response.Data.AvatarURL)
+		assert.Equal(t, "TestModeratorUser", response.Data.Username)
+	})
+}
+
+func TestDeleteCurrentUser(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+		auth: &authConfig{
+			tokenCfg: tokenConfig{
+				exp: time.Hour,
+			},
+		},
+		account: &accountConfig{
+			deletionPolicy: store.DeletionPolicyCascade,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockUserStore := app.store.Users.(*store.MockUserStore)
+	mockUserStore.On("Delete", mock.Anything, int64(1), store.DeletionPolicyCascade).Return(nil)
+	mockRevokedTokenStore := app.store.RevokedTokens.(*store.MockRevokedTokenStore)
+	mockRevokedTokenStore.On("RevokeUser", mock.Anything, int64(1), mock.Anything).Return(nil)
+
+	req, err := http.NewRequest("DELETE", "/v1/users/me", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusNoContent, rr.Code)
+	mockUserStore.AssertCalled(t, "Delete", mock.Anything, int64(1), store.DeletionPolicyCascade)
+	mockRevokedTokenStore.AssertCalled(t, "RevokeUser", mock.Anything, int64(1), mock.Anything)
+}
+
+func TestExportCurrentUser(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockPostStore := app.store.Posts.(*store.MockPostStore)
+	mockPostStore.On("GetByUserID", mock.Anything, int64(1)).Return([]*store.Post{{ID: 10, UserID: 1, Title: "Hello"}}, nil)
+	mockCommentStore := app.store.Comments.(*store.MockCommentStore)
+	mockCommentStore.On("GetByUserID", mock.Anything, int64(1)).Return([]*store.Comment{{ID: 20, PostID: 10, Content: "First"}}, nil)
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("GetFollowers", mock.Anything, int64(1), int64(1), mock.Anything).Return(
+		[]*store.FollowUser{{ID: 2, Username: "TestUser"}}, (*store.Cursor)(nil), nil)
+	mockFollowerStore.On("GetFollowing", mock.Anything, int64(1), int64(1), mock.Anything).Return(
+		[]*store.FollowUser{}, (*store.Cursor)(nil), nil)
+
+	t.Run("should export json", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/users/me/export", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		var export UserExport
+		if err := json.Unmarshal(rr.Body.Bytes(), &export); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(1), export.Profile.ID)
+		assert.Len(t, export.Posts, 1)
+		assert.Len(t, export.Comments, 1)
+		assert.Len(t, export.Followers, 1)
+		assert.Empty(t, export.Following)
+	})
+
+	t.Run("should export a zip archive", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/users/me/export?format=zip", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+		archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range archive.File {
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, []string{"profile.json", "posts.json", "comments.json", "followers.json", "following.json"}, names)
+	})
+}
+
+func TestGetUserFollowers(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	followedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	next := &store.Cursor{CreatedAt: followedAt, ID: 3}
+	mockUserStore := app.store.Users.(*store.MockUserStore)
+	mockUserStore.On("GetByID", mock.Anything, int64(99)).Return((*store.User)(nil), store.ErrNotFound)
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("GetFollowers", mock.Anything, int64(2), int64(0), mock.Anything).Return(
+		[]*store.FollowUser{{ID: 3, Username: "TestUser3", FollowedAt: followedAt}}, next, nil)
+	mockFollowerStore.On("GetFollowers", mock.Anything, int64(2), int64(1), mock.Anything).Return(
+		[]*store.FollowUser{{ID: 3, Username: "TestUser3", FollowedAt: followedAt, FollowedByMe: true}}, (*store.Cursor)(nil), nil)
+	mockFollowerStore.On("GetFollowing", mock.Anything, int64(2), int64(0), mock.Anything).Return(
+		[]*store.FollowUser{}, (*store.Cursor)(nil), nil)
+
+	t.Run("should list followers anonymously with the next cursor", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/users/2/followers?limit=1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		checkResponseBody(t, map[string]any{
+			"data": []any{
+				map[string]any{
+					"id":             float64(3),
+					"username":       "TestUser3",
+					"followed_at":    "2025-01-02T03:04:05Z",
+					"followed_by_me": false,
+				},
+			},
+			"next_cursor": next.Encode(),
+		}, rr.Body.Bytes())
+		mockFollowerStore.AssertCalled(t, "GetFollowers", mock.Anything, int64(2), int64(0), &store.PaginatedCursorQuery{Limit: 1})
+	})
+
+	t.Run("should flag users followed by the caller", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/users/2/followers?cursor="+next.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		var body struct {
+			Data       []*store.FollowUser `json:"data"`
+			NextCursor string              `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, body.Data[0].FollowedByMe)
+		assert.Empty(t, body.NextCursor)
+	})
+
+	t.Run("should list following", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/users/2/following", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		checkResponseBody(t, map[string]any{"data": []any{}}, rr.Body.Bytes())
+	})
+
+	t.Run("should reject an invalid cursor", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/users/2/followers?cursor=invalid", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should return not found for unknown users", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/v1/users/99/followers", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestFollowRequests(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateUser := &store.User{ID: 4, Username: "PrivateUser", IsPrivate: true}
+	mockUserStore := app.store.Users.(*store.MockUserStore)
+	mockUserStore.On("GetByID", mock.Anything, int64(4)).Return(privateUser, nil)
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("Follow", mock.Anything, privateUser, mock.Anything).Return(store.FollowStatusPending, nil)
+	mockFollowerStore.On("Follow", mock.Anything, mock.MatchedBy(func(u *store.User) bool { return u.ID == 2 }), mock.Anything).
+		Return(store.FollowStatusAccepted, nil)
+	mockFollowerStore.On("GetFollowRequests", mock.Anything, int64(1), mock.Anything).Return(
+		[]*store.FollowUser{{ID: 3, Username: "TestUser3"}}, (*store.Cursor)(nil), nil)
+	mockFollowerStore.On("AcceptFollowRequest", mock.Anything, int64(1), int64(3)).Return(nil)
+	mockFollowerStore.On("RejectFollowRequest", mock.Anything, int64(1), int64(2)).Return(store.ErrNotFound)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should request to follow a private account",
+			method:         "PUT",
+			path:           "/v1/users/4/follow",
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   map[string]any{"data": map[string]any{"status": "pending"}},
+		},
+		{
+			name:           "should follow a public account right away",
+			method:         "PUT",
+			path:           "/v1/users/2/follow",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "should list pending follow requests",
+			method:         "GET",
+			path:           "/v1/users/me/follow-requests",
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]any{"data": []any{
+				map[string]any{
+					"id":             float64(3),
+					"username":       "TestUser3",
+					"followed_at":    "0001-01-01T00:00:00Z",
+					"followed_by_me": false,
+				},
+			}},
+		},
+		{
+			name:           "should approve a follow request",
+			method:         "PUT",
+			path:           "/v1/users/me/follow-requests/3",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "should not reject a missing follow request",
+			method:         "DELETE",
+			path:           "/v1/users/me/follow-requests/2",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedBody != nil {
+				checkResponseBody(t, tc.expectedBody, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestBlockAndMute(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockUserStore := app.store.Users.(*store.MockUserStore)
+	mockUserStore.On("GetByID", mock.Anything, int64(99)).Return((*store.User)(nil), store.ErrNotFound)
+	mockBlockStore := app.store.Blocks.(*store.MockBlockStore)
+	mockBlockStore.On("Block", mock.Anything, int64(1), int64(2)).Return(nil)
+	mockBlockStore.On("Block", mock.Anything, int64(1), int64(3)).Return(store.ErrConflict)
+	mockBlockStore.On("Unblock", mock.Anything, int64(1), int64(2)).Return(store.ErrNotFound)
+	mockMuteStore := app.store.Mutes.(*store.MockMuteStore)
+	mockMuteStore.On("Mute", mock.Anything, int64(1), int64(2)).Return(nil)
+	mockMuteStore.On("Unmute", mock.Anything, int64(1), int64(2)).Return(nil)
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("Follow", mock.Anything, mock.Anything, mock.Anything).Return("", store.ErrBlocked)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should block a user",
+			path:           "/v1/users/2/block",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "should not block a user twice",
+			path:           "/v1/users/3/block",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "should not block oneself",
+			path:           "/v1/users/1/block",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]any{"error": errSelfRelationship.Error()},
+		},
+		{
+			name:           "should not block an unknown user",
+			path:           "/v1/users/99/block",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should not unblock a user who is not blocked",
+			path:           "/v1/users/2/unblock",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "should mute a user",
+			path:           "/v1/users/2/mute",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "should unmute a user",
+			path:           "/v1/users/2/unmute",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "should not follow a user in a block",
+			path:           "/v1/users/2/follow",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   map[string]any{"error": store.ErrBlocked.Error()},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("PUT", tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedBody != nil {
+				checkResponseBody(t, tc.expectedBody, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestFollowUserErrors(t *testing.T) {
+	cfg := config{
+		redis: &redisConfig{
+			enabled: false,
+		},
+		rateLimiter: &ratelimiter.Config{
+			Enabled: false,
+		},
+	}
+	app := newTestApp(t, cfg)
+	mux := app.mount()
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isUser := func(id int64) any {
+		return mock.MatchedBy(func(u *store.User) bool { return u.ID == id })
+	}
+	mockFollowerStore := app.store.Followers.(*store.MockFollowerStore)
+	mockFollowerStore.On("Follow", mock.Anything, isUser(1), isUser(1)).Return("", store.ErrSelfFollow)
+	mockFollowerStore.On("Follow", mock.Anything, isUser(2), isUser(1)).Return("", store.ErrConflict)
+	mockFollowerStore.On("Unfollow", mock.Anything, isUser(2), isUser(1)).Return(store.ErrNotFound)
+	mockFollowerStore.On("Unfollow", mock.Anything, isUser(3), isUser(1)).Return(nil)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   map[string]any
+	}{
+		{
+			name:           "should reject following oneself",
+			path:           "/v1/users/1/follow",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]any{"error": store.ErrSelfFollow.Error()},
+		},
+		{
+			name:           "should reject following a user twice",
+			path:           "/v1/users/2/follow",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "should return not found when unfollowing a user who is not followed",
+			path:           "/v1/users/2/unfollow",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   map[string]any{"error": "resource not found"},
+		},
+		{
+			name:           "should unfollow a followed user",
+			path:           "/v1/users/3/unfollow",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("PUT", tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.expectedStatus, rr.Code)
+			if tc.expectedBody != nil {
+				checkResponseBody(t, tc.expectedBody, rr.Body.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS password_reset (
+    user_id BIGINT CONSTRAINT pk_password_reset PRIMARY KEY,
+    CONSTRAINT fk_password_reset_user_id FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE,
+    token bytea NOT NULL UNIQUE,
+    expiration_time TIMESTAMP NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_recovery_codes;
+ALTER TABLE IF EXISTS roles DROP COLUMN IF EXISTS mfa_required;
+ALTER TABLE IF EXISTS users
+    DROP COLUMN IF EXISTS mfa_enabled,
+    DROP COLUMN IF EXISTS totp_secret;
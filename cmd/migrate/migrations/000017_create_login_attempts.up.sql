@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    CONSTRAINT pk_login_attempts PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_locked_until ON login_attempts (locked_until);
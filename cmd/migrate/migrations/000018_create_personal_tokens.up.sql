@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS personal_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL CONSTRAINT fk_personal_tokens_user_id REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash BYTEA NOT NULL CONSTRAINT uq_personal_tokens_token_hash UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_tokens_user_id ON personal_tokens (user_id);
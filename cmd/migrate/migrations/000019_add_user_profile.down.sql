@@ -0,0 +1,5 @@
+ALTER TABLE IF EXISTS user_invitation DROP COLUMN IF EXISTS new_email;
+ALTER TABLE IF EXISTS users
+    DROP COLUMN IF EXISTS avatar_url,
+    DROP COLUMN IF EXISTS bio,
+    DROP COLUMN IF EXISTS display_name;
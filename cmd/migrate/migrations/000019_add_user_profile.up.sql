@@ -0,0 +1,7 @@
+ALTER TABLE IF EXISTS users
+    ADD COLUMN IF NOT EXISTS display_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS avatar_url TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE IF EXISTS user_invitation
+    ADD COLUMN IF NOT EXISTS new_email citext;
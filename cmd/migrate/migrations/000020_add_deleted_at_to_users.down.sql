@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_follower_follower_id_created_at;
+DROP INDEX IF EXISTS idx_user_follower_user_id_created_at;
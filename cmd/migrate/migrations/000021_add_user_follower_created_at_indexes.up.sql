@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_user_follower_user_id_created_at ON user_follower (user_id, created_at DESC, follower_id DESC);
+CREATE INDEX IF NOT EXISTS idx_user_follower_follower_id_created_at ON user_follower (follower_id, created_at DESC, user_id DESC);
@@ -0,0 +1,5 @@
+ALTER TABLE IF EXISTS user_follower
+    DROP CONSTRAINT IF EXISTS chk_user_follower_status,
+    DROP COLUMN IF EXISTS status;
+
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS is_private;
@@ -0,0 +1,6 @@
+ALTER TABLE IF EXISTS users
+    ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE IF EXISTS user_follower
+    ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'accepted',
+    ADD CONSTRAINT chk_user_follower_status CHECK (status IN ('pending', 'accepted'));
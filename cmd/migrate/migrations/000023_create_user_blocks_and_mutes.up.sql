@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_user_blocks PRIMARY KEY (user_id, blocked_id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS user_mutes (
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    muted_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_user_mutes PRIMARY KEY (user_id, muted_id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE IF EXISTS user_follower
+    DROP CONSTRAINT IF EXISTS fk_user_follower_follower,
+    DROP CONSTRAINT IF EXISTS fk_user_follower_user,
+    DROP CONSTRAINT IF EXISTS chk_user_follower_not_self;
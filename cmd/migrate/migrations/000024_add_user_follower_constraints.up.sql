@@ -0,0 +1,9 @@
+DELETE FROM user_follower
+WHERE user_id = follower_id
+   OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = user_follower.user_id)
+   OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = user_follower.follower_id);
+
+ALTER TABLE IF EXISTS user_follower
+    ADD CONSTRAINT chk_user_follower_not_self CHECK (user_id <> follower_id),
+    ADD CONSTRAINT fk_user_follower_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_user_follower_follower FOREIGN KEY (follower_id) REFERENCES users (id) ON DELETE CASCADE;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS post_reactions (
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reaction VARCHAR(16) NOT NULL CHECK (reaction IN ('like', 'love', 'haha', 'wow', 'sad', 'angry')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_post_reactions PRIMARY KEY (post_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_post_reactions_user_id ON post_reactions (user_id);
+
+-- counted by a trigger so that reading the counts of a viral post does not scan its reactions
+CREATE TABLE IF NOT EXISTS post_reaction_counts (
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    reaction VARCHAR(16) NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT pk_post_reaction_counts PRIMARY KEY (post_id, reaction)
+);
+
+CREATE OR REPLACE FUNCTION count_post_reactions() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE post_reaction_counts SET count = count - 1
+        WHERE post_id = OLD.post_id AND reaction = OLD.reaction;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO post_reaction_counts (post_id, reaction, count) VALUES (NEW.post_id, NEW.reaction, 1)
+        ON CONFLICT (post_id, reaction) DO UPDATE SET count = post_reaction_counts.count + 1;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_count_post_reactions
+AFTER INSERT OR UPDATE OF reaction OR DELETE ON post_reactions
+FOR EACH ROW EXECUTE FUNCTION count_post_reactions();
@@ -0,0 +1,5 @@
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_id BIGINT CONSTRAINT fk_comments_parent_id REFERENCES comments (id);
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS depth INT NOT NULL DEFAULT 0;
+-- a deleted comment with replies is kept as a tombstone so that the thread below it stays in place
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS comment_edits;
+ALTER TABLE comments DROP COLUMN IF EXISTS version;
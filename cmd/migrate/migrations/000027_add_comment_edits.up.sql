@@ -0,0 +1,12 @@
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
+
+-- the content a comment had before each edit, for moderators
+CREATE TABLE IF NOT EXISTS comment_edits (
+    id BIGSERIAL PRIMARY KEY,
+    comment_id BIGINT NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    version INT NOT NULL,
+    edited_by BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_comment_edits_comment_id ON comment_edits (comment_id);
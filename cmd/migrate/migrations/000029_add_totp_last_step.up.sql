@@ -0,0 +1,2 @@
+-- the time step of the last accepted TOTP code, so that a code cannot be used twice
+ALTER TABLE IF EXISTS users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
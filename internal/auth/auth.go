@@ -1,8 +1,24 @@
 package auth
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type Authenticator interface {
 	GenerateToken(claims jwt.Claims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
 }
+
+// KeySetProvider is implemented by authenticators that sign tokens with
+// asymmetric keys and can publish their public keys.
+type KeySetProvider interface {
+	JWKS() JWKSet
+}
+
+// IdentityProvider signs users in with the OpenID Connect authorization code flow with PKCE.
+type IdentityProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error)
+}
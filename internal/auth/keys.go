@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// KeySetAuthenticator signs tokens with RSA or Ed25519 private keys. Every loaded
+// key is accepted for validation and published through JWKS, while only the
+// active one is used for signing, which allows keys to be rotated without
+// invalidating tokens that are still in flight.
+type KeySetAuthenticator struct {
+	keys      map[string]*signingKey
+	activeKid string
+	aud       string
+	iss       string
+}
+
+// NewKeySetAuthenticator loads PEM encoded private keys from keyFiles, a map of kid to file path.
+func NewKeySetAuthenticator(keyFiles map[string]string, activeKid, audience, issuer string) (*KeySetAuthenticator, error) {
+	keys := make(map[string]*signingKey, len(keyFiles))
+	for kid, path := range keyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key %s: %w", kid, err)
+		}
+		key, err := parseSigningKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %s: %w", kid, err)
+		}
+		keys[kid] = key
+	}
+	if _, ok := keys[activeKid]; !ok {
+		return nil, fmt.Errorf("active key %q is not loaded", activeKid)
+	}
+	return &KeySetAuthenticator{
+		keys:      keys,
+		activeKid: activeKid,
+		aud:       audience,
+		iss:       issuer,
+	}, nil
+}
+
+func parseSigningKey(data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var (
+		parsed any
+		err    error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{method: jwt.SigningMethodRS256, private: key}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{method: jwt.SigningMethodEdDSA, private: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+func (a *KeySetAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	key := a.keys[a.activeKid]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = a.activeKid
+	return token.SignedString(key.private)
+}
+
+func (a *KeySetAuthenticator) ValidateToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+		return key.private.Public(), nil
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(a.aud),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (a *KeySetAuthenticator) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(a.keys))}
+	for kid, key := range a.keys {
+		jwk := JWK{
+			Kid: kid,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyFiles(t *testing.T) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPath := filepath.Join(dir, "rsa.pem")
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	require.NoError(t, os.WriteFile(rsaPath, rsaPEM, 0o600))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edBytes, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edPath := filepath.Join(dir, "ed25519.pem")
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edBytes})
+	require.NoError(t, os.WriteFile(edPath, edPEM, 0o600))
+
+	return map[string]string{
+		"rsa-1": rsaPath,
+		"ed-1":  edPath,
+	}
+}
+
+func TestKeySetAuthenticator(t *testing.T) {
+	keyFiles := writeKeyFiles(t)
+	claims := jwt.MapClaims{
+		"sub": 1,
+		"aud": "aud",
+		"iss": "iss",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	t.Run("should sign with the active key and publish every key", func(t *testing.T) {
+		authenticator, err := NewKeySetAuthenticator(keyFiles, "ed-1", "aud", "iss")
+		require.NoError(t, err)
+
+		tokenString, err := authenticator.GenerateToken(claims)
+		require.NoError(t, err)
+		token, err := authenticator.ValidateToken(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, "ed-1", token.Header["kid"])
+		assert.Equal(t, jwt.SigningMethodEdDSA.Alg(), token.Method.Alg())
+
+		jwks := authenticator.JWKS()
+		require.Len(t, jwks.Keys, 2)
+		assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+		assert.NotEmpty(t, jwks.Keys[0].X)
+		assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+		assert.NotEmpty(t, jwks.Keys[1].N)
+		assert.Equal(t, "AQAB", jwks.Keys[1].E)
+	})
+
+	t.Run("should accept tokens signed by a rotated key", func(t *testing.T) {
+		previous, err := NewKeySetAuthenticator(keyFiles, "rsa-1", "aud", "iss")
+		require.NoError(t, err)
+		current, err := NewKeySetAuthenticator(keyFiles, "ed-1", "aud", "iss")
+		require.NoError(t, err)
+
+		tokenString, err := previous.GenerateToken(claims)
+		require.NoError(t, err)
+		_, err = current.ValidateToken(tokenString)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject tokens with an unknown kid", func(t *testing.T) {
+		authenticator, err := NewKeySetAuthenticator(map[string]string{"ed-1": keyFiles["ed-1"]}, "ed-1", "aud", "iss")
+		require.NoError(t, err)
+		other, err := NewKeySetAuthenticator(map[string]string{"rsa-1": keyFiles["rsa-1"]}, "rsa-1", "aud", "iss")
+		require.NoError(t, err)
+
+		tokenString, err := other.GenerateToken(claims)
+		require.NoError(t, err)
+		_, err = authenticator.ValidateToken(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("should fail when the active key is not loaded", func(t *testing.T) {
+		_, err := NewKeySetAuthenticator(keyFiles, "missing", "aud", "iss")
+		assert.Error(t, err)
+	})
+}
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"net/url"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,6 +12,7 @@ import (
 const secret = "test_secret"
 
 var testClaims = jwt.MapClaims{
+	"jti": "8b5f1c2e-3d4a-4f6b-9c7d-1e2f3a4b5c6d",
 	"aud": "test_aud",
 	"iss": "test_aud",
 	"sub": int64(1),
@@ -34,3 +38,24 @@ func (m *MockAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 		return []byte(secret), nil
 	})
 }
+
+// MockIdentityProvider returns Identity for any code when the code verifier and nonce match those of the
+// last AuthCodeURL, like a provider checking PKCE.
+type MockIdentityProvider struct {
+	Identity      *OIDCIdentity
+	codeChallenge string
+	nonce         string
+}
+
+func (m *MockIdentityProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	m.codeChallenge = PKCEChallenge(codeVerifier)
+	m.nonce = nonce
+	return "https://provider.example.com/authorize?state=" + url.QueryEscape(state), nil
+}
+
+func (m *MockIdentityProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	if PKCEChallenge(codeVerifier) != m.codeChallenge || nonce != m.nonce {
+		return nil, errors.New("invalid_grant")
+	}
+	return m.Identity, nil
+}
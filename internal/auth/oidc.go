@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// OIDCIdentity is the verified identity returned by the provider in the ID token.
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCProvider implements the OpenID Connect authorization code flow with PKCE.
+// Provider metadata and keys are discovered lazily on first use.
+type OIDCProvider struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// PKCEChallenge derives the S256 code challenge from a code verifier.
+func PKCEChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to for signing in.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.cfg.ClientID)
+	values.Set("redirect_uri", p.cfg.RedirectURL)
+	values.Set("scope", "openid email profile")
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", PKCEChallenge(codeVerifier))
+	values.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Exchange redeems the authorization code and returns the identity from the verified ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.cfg.RedirectURL)
+	values.Set("client_id", p.cfg.ClientID)
+	values.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret != "" {
+		values.Set("client_secret", p.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(req, &tokenResponse); err != nil {
+		return nil, err
+	}
+	if tokenResponse.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+	if tokenResponse.IDToken == "" {
+		return nil, errors.New("token response does not contain an id_token")
+	}
+	return p.verifyIDToken(ctx, discovery, tokenResponse.IDToken, nonce)
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, idToken, nonce string) (*OIDCIdentity, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, discovery, kid)
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}))
+	if err != nil {
+		return nil, err
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("id_token nonce does not match")
+	}
+	identity := &OIDCIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("id_token does not contain a subject")
+	}
+	return identity, nil
+}
+
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	wellKnown := strings.TrimSuffix(p.cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	discovery := &oidcDiscovery{}
+	if err := p.doJSON(req, discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is incomplete")
+	}
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// getKey returns the provider key with the given kid, refreshing the key set once
+// when the kid is unknown so rotated provider keys are picked up.
+func (p *OIDCProvider) getKey(ctx context.Context, discovery *oidcDiscovery, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set JWKSet
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("fetching oidc keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		public, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = public
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, data any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(data)
+}
+
+// PublicKey decodes the key into an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
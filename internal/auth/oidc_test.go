@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockOIDCServer struct {
+	*httptest.Server
+	key           *rsa.PrivateKey
+	codeChallenge string
+	nonce         string
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockOIDCServer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(JWKSet{Keys: []JWK{{
+			Kty: "RSA",
+			Kid: "mock",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || PKCEChallenge(r.PostForm.Get("code_verifier")) != m.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            m.URL,
+			"aud":            "client",
+			"sub":            "provider-user-1",
+			"email":          "oidc@mail.com",
+			"email_verified": true,
+			"nonce":          m.nonce,
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "mock"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestOIDCProvider(t *testing.T) {
+	server := newMockOIDCServer(t)
+	provider := NewOIDCProvider(OIDCConfig{
+		IssuerURL:   server.URL,
+		ClientID:    "client",
+		RedirectURL: "http://localhost/callback",
+	})
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "/authorize", parsed.Path)
+	assert.Equal(t, "state", parsed.Query().Get("state"))
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	server.codeChallenge = parsed.Query().Get("code_challenge")
+	server.nonce = parsed.Query().Get("nonce")
+
+	t.Run("should return the verified identity", func(t *testing.T) {
+		identity, err := provider.Exchange(ctx, "code", "verifier", "nonce")
+		require.NoError(t, err)
+		assert.Equal(t, "provider-user-1", identity.Subject)
+		assert.Equal(t, "oidc@mail.com", identity.Email)
+		assert.True(t, identity.EmailVerified)
+	})
+
+	t.Run("should fail with a wrong code verifier", func(t *testing.T) {
+		_, err := provider.Exchange(ctx, "code", "other", "nonce")
+		assert.Error(t, err)
+	})
+
+	t.Run("should fail with a wrong nonce", func(t *testing.T) {
+		_, err := provider.Exchange(ctx, "code", "verifier", "other")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpDigits = 6
+	totpPeriod = 30 * time.Second
+	// totpSkew is the number of periods accepted before and after the current one
+	// to tolerate clock drift between the server and the authenticator app.
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random base32 encoded secret suitable for authenticator apps.
+func GenerateTOTPSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(key), nil
+}
+
+// TOTPURI builds the otpauth:// URI authenticator apps use to enroll the secret, usually rendered as a QR code.
+func TOTPURI(issuer, account, secret string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprintf("%d", totpDigits))
+	values.Set("period", fmt.Sprintf("%d", int(totpPeriod.Seconds())))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// ValidateTOTP reports whether code is a valid RFC 6238 code for secret at time t.
+func ValidateTOTP(secret, code string, t time.Time) bool {
+	_, ok := MatchTOTP(secret, code, t)
+	return ok
+}
+
+// MatchTOTP returns the time step code is valid for at time t, so that callers can refuse a code
+// of a step at or before the last one they accepted, as the same code stays valid for several periods.
+func MatchTOTP(secret, code string, t time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	counter := t.Unix() / int64(totpPeriod.Seconds())
+	for i := -totpSkew; i <= totpSkew; i++ {
+		expected := totpCode(key, uint64(counter+int64(i)))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// GenerateTOTP returns the code for secret at time t.
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, uint64(t.Unix()/int64(totpPeriod.Seconds()))), nil
+}
+
+func totpCode(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
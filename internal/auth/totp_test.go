@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// RFC 6238 appendix B test secret, truncated to six digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	test := []struct {
+		name     string
+		code     string
+		at       time.Time
+		expected bool
+	}{
+		{name: "should accept the code for the current period", code: "287082", at: time.Unix(59, 0), expected: true},
+		{name: "should accept the code for the previous period", code: "287082", at: time.Unix(89, 0), expected: true},
+		{name: "should reject an expired code", code: "287082", at: time.Unix(150, 0), expected: false},
+		{name: "should accept the code at a later time", code: "081804", at: time.Unix(1111111109, 0), expected: true},
+		{name: "should reject a wrong code", code: "000000", at: time.Unix(59, 0), expected: false},
+		{name: "should reject a code of wrong length", code: "87082", at: time.Unix(59, 0), expected: false},
+	}
+
+	for _, tc := range test {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ValidateTOTP(secret, tc.code, tc.at))
+		})
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	step, ok := MatchTOTP(secret, "287082", time.Unix(89, 0))
+	assert.True(t, ok)
+	// the code of the previous period is accepted for the skew, but its step is the one it was generated for
+	assert.Equal(t, int64(1), step)
+
+	code, err := GenerateTOTP(secret, time.Unix(1111111109, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "081804", code)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("gopher.social", "test@mail.com", "SECRET")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/gopher.social:test@mail.com?"))
+	assert.Contains(t, uri, "secret=SECRET")
+	assert.Contains(t, uri, "issuer=gopher.social")
+}
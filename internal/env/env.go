@@ -36,3 +36,15 @@ func GetBool(key string, fallback bool) bool {
 	}
 	return b
 }
+
+func GetFloat(key string, fallback float64) float64 {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(env, 64)
+	if err != nil {
+		return fallback
+	}
+	return f
+}
@@ -0,0 +1,35 @@
+// Package events delivers real-time events to the users connected to the API.
+package events
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const (
+	TypePost    = "post"
+	TypeComment = "comment"
+)
+
+// Event is a change a user is notified about, such as a new post of a followed user.
+type Event struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// NewEvent returns an event of the type carrying data encoded as JSON.
+func NewEvent(eventType string, data any) (Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: eventType, Data: encoded}, nil
+}
+
+// Broker passes events from the instance of the API where they happen to the subscribed users,
+// which may be connected to another instance.
+type Broker interface {
+	Publish(ctx context.Context, event Event, userIDs ...int64) error
+	// Subscribe returns the events of the user until ctx is done, when the channel is closed.
+	Subscribe(ctx context.Context, userID int64) (<-chan Event, error)
+}
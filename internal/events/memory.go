@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is the number of events kept for a subscriber that is slow to read them.
+// Further events are dropped until the subscriber catches up.
+const subscriberBuffer = 16
+
+// MemoryBroker delivers events to the subscribers connected to the same instance of the API.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan Event]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: make(map[int64]map[chan Event]struct{})}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, event Event, userIDs ...int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, userID := range userIDs {
+		for ch := range b.subscribers[userID] {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, userID int64) (<-chan Event, error) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[userID], ch)
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	broker := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription, err := broker.Subscribe(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := NewEvent(TypePost, map[string]int{"id": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Publish(context.Background(), event, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Publish(context.Background(), event, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case received := <-subscription:
+		if received.Type != TypePost || string(received.Data) != `{"id":10}` {
+			t.Fatalf("unexpected event %+v", received)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an event")
+	}
+	select {
+	case received := <-subscription:
+		t.Fatalf("expected only the event of the user, got %+v", received)
+	default:
+	}
+
+	t.Run("should drop events a slow subscriber has no room for", func(t *testing.T) {
+		for i := 0; i < subscriberBuffer+1; i++ {
+			if err := broker.Publish(context.Background(), event, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(subscription) != subscriberBuffer {
+			t.Fatalf("expected %d buffered events, got %d", subscriberBuffer, len(subscription))
+		}
+	})
+
+	t.Run("should close the subscription when the context is done", func(t *testing.T) {
+		cancel()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case _, ok := <-subscription:
+				if !ok {
+					return
+				}
+			case <-timeout:
+				t.Fatal("expected the subscription to be closed")
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisBroker delivers events through Redis pub/sub to the subscribers connected to any instance of the API.
+type RedisBroker struct {
+	client *redis.Client
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{client: client}
+}
+
+func eventsChannel(userID int64) string {
+	return fmt.Sprintf("events-%d", userID)
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, event Event, userIDs ...int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	pipe := b.client.Pipeline()
+	for _, userID := range userIDs {
+		pipe.Publish(ctx, eventsChannel(userID), message)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, userID int64) (<-chan Event, error) {
+	pubsub := b.client.Subscribe(ctx, eventsChannel(userID))
+	// the subscription is confirmed before returning so that no event published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	go func() {
+		defer close(ch)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event Event
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					continue
+				}
+				select {
+				case ch <- event:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -3,9 +3,12 @@ package mailer
 import "embed"
 
 const (
-	fromName           = "GopherSocial"
-	maxRetries         = 3
-	UserInviteTemplate = "user_inivatation.tmpl"
+	fromName              = "GopherSocial"
+	maxRetries            = 3
+	UserInviteTemplate    = "user_inivatation.tmpl"
+	PasswordResetTemplate = "password_reset.tmpl"
+	AccountLockedTemplate = "account_locked.tmpl"
+	EmailChangeTemplate   = "email_change.tmpl"
 )
 
 //go:embed "templates"
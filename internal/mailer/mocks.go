@@ -0,0 +1,12 @@
+package mailer
+
+import "github.com/stretchr/testify/mock"
+
+type MockClient struct {
+	mock.Mock
+}
+
+func (m *MockClient) Send(templateFile, username, email string, data any, isSendbox bool) error {
+	args := m.Called(templateFile, username, email, data, isSendbox)
+	return args.Error(0)
+}
@@ -0,0 +1,67 @@
+// Package ranking scores feed posts for the ranked feed.
+package ranking
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Weights tune how much each signal adds to the score of a post.
+type Weights struct {
+	// Recency is the score of a fresh post without any engagement
+	Recency float64
+	// Comments, Likes and Affinity weigh the logarithm of their counts, so the first interactions
+	// count the most
+	Comments float64
+	Likes    float64
+	Affinity float64
+	// SecondDegree scales the score of posts of users the viewer does not follow but the followed users do
+	SecondDegree float64
+	// HalfLife is the age at which the score of a post is halved
+	HalfLife time.Duration
+}
+
+// Signals are what is known about a post when it is ranked.
+type Signals struct {
+	CreatedAt time.Time
+	Comments  int
+	Likes     int
+	// Affinity is the number of the interactions of the viewer with the author of the post
+	Affinity     int
+	SecondDegree bool
+}
+
+// Score returns the score of a post at the time now. The engagement of the post is added to its recency
+// and the sum decays exponentially with the age of the post.
+func Score(signals Signals, weights Weights, now time.Time) float64 {
+	score := weights.Recency +
+		weights.Comments*math.Log1p(float64(signals.Comments)) +
+		weights.Likes*math.Log1p(float64(signals.Likes)) +
+		weights.Affinity*math.Log1p(float64(signals.Affinity))
+	if age := now.Sub(signals.CreatedAt); age > 0 && weights.HalfLife > 0 {
+		score *= math.Exp2(-float64(age) / float64(weights.HalfLife))
+	}
+	if signals.SecondDegree {
+		score *= weights.SecondDegree
+	}
+	return score
+}
+
+// Rank sorts the items from the highest score to the lowest, keeping the order of items with equal scores.
+func Rank[T any](items []T, signals func(T) Signals, weights Weights, now time.Time) {
+	scores := make([]float64, len(items))
+	indexes := make([]int, len(items))
+	for i, item := range items {
+		indexes[i] = i
+		scores[i] = Score(signals(item), weights, now)
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return scores[indexes[a]] > scores[indexes[b]]
+	})
+	ranked := make([]T, len(items))
+	for i, index := range indexes {
+		ranked[i] = items[index]
+	}
+	copy(items, ranked)
+}
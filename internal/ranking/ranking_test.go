@@ -0,0 +1,65 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var testWeights = Weights{
+	Recency:      1,
+	Comments:     0.5,
+	Likes:        0.3,
+	Affinity:     0.4,
+	SecondDegree: 0.5,
+	HalfLife:     12 * time.Hour,
+}
+
+func TestScore(t *testing.T) {
+	now := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		signals  Signals
+		expected float64
+	}{
+		{name: "fresh post", signals: Signals{CreatedAt: now}, expected: 1},
+		{name: "post of one half-life", signals: Signals{CreatedAt: now.Add(-12 * time.Hour)}, expected: 0.5},
+		{name: "post from the future", signals: Signals{CreatedAt: now.Add(time.Hour)}, expected: 1},
+		{name: "comments", signals: Signals{CreatedAt: now, Comments: 3}, expected: 1 + 0.5*math.Log(4)},
+		{name: "likes", signals: Signals{CreatedAt: now, Likes: 3}, expected: 1 + 0.3*math.Log(4)},
+		{name: "affinity", signals: Signals{CreatedAt: now, Affinity: 3}, expected: 1 + 0.4*math.Log(4)},
+		{name: "second degree", signals: Signals{CreatedAt: now.Add(-12 * time.Hour), SecondDegree: true}, expected: 0.25},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Score(tc.signals, testWeights, now); math.Abs(got-tc.expected) > 1e-9 {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	posts := map[string]Signals{
+		"fresh":         {CreatedAt: now.Add(-time.Hour)},
+		"also fresh":    {CreatedAt: now.Add(-time.Hour)},
+		"old":           {CreatedAt: now.Add(-48 * time.Hour)},
+		"old discussed": {CreatedAt: now.Add(-24 * time.Hour), Comments: 50},
+		"close friend":  {CreatedAt: now.Add(-6 * time.Hour), Affinity: 20},
+		"friend of friend": {
+			CreatedAt: now.Add(-time.Hour), SecondDegree: true,
+		},
+	}
+	items := []string{"old", "friend of friend", "fresh", "old discussed", "also fresh", "close friend"}
+
+	Rank(items, func(name string) Signals { return posts[name] }, testWeights, now)
+
+	expected := []string{"close friend", "fresh", "also fresh", "old discussed", "friend of friend", "old"}
+	for i := range expected {
+		if items[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, items)
+		}
+	}
+}
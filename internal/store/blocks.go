@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// BlockStore keeps the users a user has blocked. A block cuts every relationship between the two users
+// and hides their content from each other.
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block blocks blockedID for the user and removes the follow relationships between them in both directions.
+func (s *BlockStore) Block(ctx context.Context, userID, blockedID int64) error {
+	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+		defer cancel()
+		query := `INSERT INTO user_blocks (user_id, blocked_id) VALUES ($1, $2)`
+		if _, err := trx.ExecContext(ctx, query, userID, blockedID); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+		query = `
+DELETE FROM user_follower
+WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)`
+		_, err := trx.ExecContext(ctx, query, userID, blockedID)
+		return err
+	})
+}
+
+// Unblock removes the block, returning ErrNotFound if the user has not blocked blockedID.
+func (s *BlockStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2`
+	res, err := s.db.ExecContext(ctx, query, userID, blockedID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IsBlocked reports whether either of the users has blocked the other.
+func (s *BlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_id = $1 AND blocked_id = $2) OR (user_id = $2 AND blocked_id = $1)
+)`
+	var blocked bool
+	if err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+	return blocked, nil
+}
@@ -10,5 +10,13 @@ type Cache struct {
 	Users interface {
 		Get(ctx context.Context, id int64) (*store.User, error)
 		Set(ctx context.Context, u store.User) error
+		Delete(ctx context.Context, ids ...int64) error
+	}
+	RevokedTokens store.TokenDenylist
+	Timelines     interface {
+		Get(ctx context.Context, userID int64, after *store.Cursor, count int) (*store.FeedTimeline, error)
+		Push(ctx context.Context, post *store.Post, followerIDs []int64, maxLength int) error
+		AddCelebrity(ctx context.Context, userID int64) error
+		Delete(ctx context.Context, userIDs ...int64) error
 	}
 }
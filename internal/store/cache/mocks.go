@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/NikolayProkopchuk/social/internal/store"
 	"github.com/stretchr/testify/mock"
@@ -9,7 +10,9 @@ import (
 
 func NewMockCache() *Cache {
 	return &Cache{
-		Users: &MockUserCache{},
+		Users:         &MockUserCache{},
+		RevokedTokens: &MockRevokedTokenCache{},
+		Timelines:     &MockTimelineCache{},
 	}
 }
 
@@ -30,3 +33,57 @@ func (m *MockUserCache) Set(ctx context.Context, u store.User) error {
 	args := m.Called(ctx, u)
 	return args.Error(0)
 }
+
+func (m *MockUserCache) Delete(ctx context.Context, ids ...int64) error {
+	args := m.Called(ctx, ids)
+	return args.Error(0)
+}
+
+type MockRevokedTokenCache struct {
+	mock.Mock
+}
+
+func (m *MockRevokedTokenCache) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevokedTokenCache) Claim(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	args := m.Called(ctx, jti, expiresAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRevokedTokenCache) RevokeUser(ctx context.Context, userID int64, expiresAt time.Time) error {
+	args := m.Called(ctx, userID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevokedTokenCache) IsRevoked(ctx context.Context, jti string, userID int64, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, jti, userID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockTimelineCache struct {
+	mock.Mock
+}
+
+func (m *MockTimelineCache) Get(ctx context.Context, userID int64, after *store.Cursor, count int) (*store.FeedTimeline, error) {
+	args := m.Called(ctx, userID, after, count)
+	timeline, _ := args.Get(0).(*store.FeedTimeline)
+	return timeline, args.Error(1)
+}
+
+func (m *MockTimelineCache) Push(ctx context.Context, post *store.Post, followerIDs []int64, maxLength int) error {
+	args := m.Called(ctx, post, followerIDs, maxLength)
+	return args.Error(0)
+}
+
+func (m *MockTimelineCache) AddCelebrity(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockTimelineCache) Delete(ctx context.Context, userIDs ...int64) error {
+	args := m.Called(ctx, userIDs)
+	return args.Error(0)
+}
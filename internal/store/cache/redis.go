@@ -15,6 +15,8 @@ func NewRedisClient(addr, password string, db int) *redis.Client {
 
 func NewRedisStorage(client *redis.Client) *Cache {
 	return &Cache{
-		Users: &userCache{client: client},
+		Users:         &userCache{client: client},
+		RevokedTokens: &revokedTokenCache{client: client},
+		Timelines:     &timelineCache{client: client},
 	}
 }
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type revokedTokenCache struct {
+	client *redis.Client
+}
+
+func (cache *revokedTokenCache) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf("revoked-token-%s", jti)
+	return cache.client.Set(ctx, key, 1, ttl).Err()
+}
+
+func (cache *revokedTokenCache) Claim(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+	key := fmt.Sprintf("revoked-token-%s", jti)
+	return cache.client.SetNX(ctx, key, 1, ttl).Result()
+}
+
+func (cache *revokedTokenCache) RevokeUser(ctx context.Context, userID int64, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf("revoked-user-%d", userID)
+	return cache.client.Set(ctx, key, time.Now().UnixMicro(), ttl).Err()
+}
+
+func (cache *revokedTokenCache) IsRevoked(ctx context.Context, jti string, userID int64, issuedAt time.Time) (bool, error) {
+	exists, err := cache.client.Exists(ctx, fmt.Sprintf("revoked-token-%s", jti)).Result()
+	if err != nil {
+		return false, err
+	}
+	if exists > 0 {
+		return true, nil
+	}
+	data, err := cache.client.Get(ctx, fmt.Sprintf("revoked-user-%d", userID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+	revokedAt, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return issuedBefore(issuedAt, revokedAt), nil
+}
+
+// issuedBefore reports whether a token issued at issuedAt was issued before revokedAt, in microseconds.
+func issuedBefore(issuedAt time.Time, revokedAt int64) bool {
+	return issuedAt.UnixMicro() < revokedAt
+}
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIssuedBefore(t *testing.T) {
+	revokedAt := time.Date(2025, 1, 2, 3, 4, 5, 500_000_000, time.UTC)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		expected bool
+	}{
+		{name: "issued before the revocation", issuedAt: revokedAt.Add(-time.Second), expected: true},
+		{name: "issued earlier in the same second as the revocation", issuedAt: revokedAt.Add(-time.Millisecond), expected: true},
+		{name: "issued later in the same second as the revocation", issuedAt: revokedAt.Add(time.Millisecond), expected: false},
+		{name: "issued after the revocation", issuedAt: revokedAt.Add(time.Second), expected: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := issuedBefore(tc.issuedAt, revokedAt.UnixMicro()); got != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
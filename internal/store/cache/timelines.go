@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/NikolayProkopchuk/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+type timelineCache struct {
+	client *redis.Client
+}
+
+// timelines of users who stop reading their feed expire instead of growing forever
+const timelineExpiration = time.Hour * 24 * 7
+
+const celebritiesKey = "timeline-celebrities"
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%d", userID)
+}
+
+// Get returns the window of the timeline of the user of up to count posts after the cursor, newest first,
+// or ErrNotFound when the user has no timeline.
+func (cache *timelineCache) Get(ctx context.Context, userID int64, after *store.Cursor, count int) (*store.FeedTimeline, error) {
+	key := timelineKey(userID)
+	maxScore := "+inf"
+	pipe := cache.client.Pipeline()
+	oldestCmd := pipe.ZRangeWithScores(ctx, key, 0, 0)
+	var tiedCmd *redis.ZSliceCmd
+	if after != nil {
+		// posts created in the same microsecond as the cursor are ordered by ID like the feed
+		score := strconv.FormatInt(after.CreatedAt.UnixMicro(), 10)
+		tiedCmd = pipe.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Min: score, Max: score})
+		maxScore = "(" + score
+	}
+	entriesCmd := pipe.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Min: "-inf", Max: maxScore, Count: int64(count)})
+	celebritiesCmd := pipe.SMembers(ctx, celebritiesKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if len(oldestCmd.Val()) == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	entries, err := timelineEntries(entriesCmd.Val())
+	if err != nil {
+		return nil, err
+	}
+	if tiedCmd != nil {
+		tied, err := timelineEntries(tiedCmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range tied {
+			if entry.ID < after.ID {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	if len(entries) > count {
+		entries = entries[:count]
+	}
+
+	timeline := &store.FeedTimeline{
+		PostIDs: make([]int64, 0, len(entries)),
+		Oldest:  time.UnixMicro(int64(oldestCmd.Val()[0].Score)),
+	}
+	for _, entry := range entries {
+		timeline.PostIDs = append(timeline.PostIDs, entry.ID)
+	}
+	if len(entries) == count {
+		last := entries[len(entries)-1]
+		timeline.Last = &last
+	}
+	timeline.CelebrityIDs = make([]int64, 0, len(celebritiesCmd.Val()))
+	for _, member := range celebritiesCmd.Val() {
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		timeline.CelebrityIDs = append(timeline.CelebrityIDs, id)
+	}
+	return timeline, nil
+}
+
+// timelineEntries returns the positions of the posts of the timeline scored by their creation time.
+func timelineEntries(members []redis.Z) ([]store.Cursor, error) {
+	entries := make([]store.Cursor, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseInt(member.Member.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, store.Cursor{CreatedAt: time.UnixMicro(int64(member.Score)), ID: id})
+	}
+	return entries, nil
+}
+
+// Push adds the post to the timelines of the followers, keeping the newest maxLength posts of each.
+func (cache *timelineCache) Push(ctx context.Context, post *store.Post, followerIDs []int64, maxLength int) error {
+	if len(followerIDs) == 0 {
+		return nil
+	}
+	// microseconds keep the score exact in a float64 and match the precision of Postgres timestamps
+	member := &redis.Z{Score: float64(post.CreatedAt.UnixMicro()), Member: post.ID}
+	pipe := cache.client.Pipeline()
+	for _, followerID := range followerIDs {
+		key := timelineKey(followerID)
+		pipe.ZAdd(ctx, key, member)
+		pipe.ZRemRangeByRank(ctx, key, 0, int64(-maxLength-1))
+		pipe.Expire(ctx, key, timelineExpiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AddCelebrity marks the user as one whose posts are not pushed to timelines. Users are never unmarked,
+// as their earlier posts are missing from the timelines.
+func (cache *timelineCache) AddCelebrity(ctx context.Context, userID int64) error {
+	return cache.client.SAdd(ctx, celebritiesKey, userID).Err()
+}
+
+// Delete drops the timelines of the users, which start over with the next pushed post.
+func (cache *timelineCache) Delete(ctx context.Context, userIDs ...int64) error {
+	keys := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		keys = append(keys, timelineKey(userID))
+	}
+	return cache.client.Del(ctx, keys...).Err()
+}
@@ -42,3 +42,11 @@ func (cache *userCache) Set(ctx context.Context, user store.User) error {
 	status := cache.client.Set(ctx, key, data, userExpiration)
 	return status.Err()
 }
+
+func (cache *userCache) Delete(ctx context.Context, ids ...int64) error {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf("user-%d", id))
+	}
+	return cache.client.Del(ctx, keys...).Err()
+}
@@ -3,78 +3,404 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// Comment is a comment on a post or a reply to another comment of the post. A deleted comment with replies
+// is a tombstone without content and author.
 type Comment struct {
-	ID        int64        `json:"id"`
-	PostID    int64        `json:"post_id"`
-	Content   string       `json:"content"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt sql.NullTime `json:"updated_at"`
-	User      *User        `json:"user"`
+	ID         int64        `json:"id"`
+	PostID     int64        `json:"post_id"`
+	ParentID   *int64       `json:"parent_id"`
+	Depth      int          `json:"depth"`
+	Content    string       `json:"content"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  sql.NullTime `json:"updated_at"`
+	DeletedAt  *time.Time   `json:"deleted_at,omitempty"`
+	Version    int          `json:"version"`
+	User       *User        `json:"user"`
+	ReplyCount int          `json:"reply_count"`
+	Replies    []*Comment   `json:"replies,omitempty"`
+}
+
+// CommentEdit is the content a comment had before it was edited.
+type CommentEdit struct {
+	ID        int64     `json:"id"`
+	CommentID int64     `json:"comment_id"`
+	Content   string    `json:"content"`
+	Version   int       `json:"version"`
+	EditedBy  int64     `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Comment, error) {
+// commentColumns are the columns scanned by scanComment from comments aliased c joined with users aliased u.
+const commentColumns = `c.id, c.post_id, c.parent_id, c.depth, c.user_id, u.username, c.content, c.created_at,
+       c.updated_at, c.deleted_at, c.version`
+
+// visibleToViewer leaves out the comments of users in a block with the viewer passed as $2.
+const visibleToViewer = `NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.user_id = $2 AND b.blocked_id = c.user_id) OR (b.user_id = c.user_id AND b.blocked_id = $2)
+)`
+
+func scanComment(row interface{ Scan(...any) error }, extra ...any) (*Comment, error) {
+	comment := &Comment{User: &User{}}
+	dest := append([]any{
+		&comment.ID,
+		&comment.PostID,
+		&comment.ParentID,
+		&comment.Depth,
+		&comment.User.ID,
+		&comment.User.Username,
+		&comment.Content,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.DeletedAt,
+		&comment.Version,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if comment.DeletedAt != nil {
+		comment.User = nil
+	}
+	return comment, nil
+}
+
+// GetReplies returns the replies to the comments of the post, nested ones included, in the order they were
+// written, leaving out the ones of users in a block with viewerID and the replies under them.
+func (s *CommentStore) GetReplies(ctx context.Context, postID int64, parentIDs []int64, viewerID int64) ([]*Comment, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
 	query := `
-SELECT c.id,
-       c.post_id,
-       c.user_id,
-       u.username,
-       c.content,
-       c.created_at,
-       c.updated_at
-FROM comments c
+WITH RECURSIVE thread AS (
+    SELECT c.id FROM comments c
+    WHERE c.post_id = $1 AND c.parent_id = ANY($3::bigint[])
+    AND ` + visibleToViewer + `
+    UNION ALL
+    SELECT c.id FROM comments c
+    JOIN thread t ON c.parent_id = t.id
+    WHERE ` + visibleToViewer + `
+)
+SELECT ` + commentColumns + `
+FROM thread t
+JOIN comments c ON c.id = t.id
 JOIN users u ON u.id = c.user_id
-WHERE c.post_id = $1`
+ORDER BY c.created_at, c.id`
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID, parentIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var comments []*Comment
 	for rows.Next() {
-		var comment Comment
-		var user User
-		comment.User = &user
-		if err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.User.ID,
-			&comment.User.Username,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.UpdatedAt,
-		); err != nil {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// CommentTree nests the comments of a post under their parents and returns the top-level ones, which
+// come first in the list, in their order. Replies follow in the order they were written, and the ones to
+// comments that are not in the list are left out. The reply counts are those of the replies in the list.
+func CommentTree(comments []*Comment) []*Comment {
+	byID := make(map[int64]*Comment, len(comments))
+	roots := []*Comment{}
+	for _, comment := range comments {
+		comment.ReplyCount = 0
+		byID[comment.ID] = comment
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+			continue
+		}
+		if parent, ok := byID[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+			parent.ReplyCount++
+		}
+	}
+	return roots
+}
+
+// GetByID returns the comment, including a tombstone.
+func (s *CommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT ` + commentColumns + `
+FROM comments c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = $1`
+	comment, err := scanComment(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return comment, nil
+}
+
+// commentOrders maps the sorts of comments to their ORDER BY clauses and the conditions of the cursor
+// of the next page on the arguments $4, $5 and, for the top comments, $7.
+// Only these clauses ever reach the SQL text.
+var commentOrders = map[string]struct{ orderBy, after string }{
+	"":                {"c.created_at, c.id", "(c.created_at, c.id) > ($4, $5)"},
+	CommentSortOldest: {"c.created_at, c.id", "(c.created_at, c.id) > ($4, $5)"},
+	CommentSortNewest: {"c.created_at DESC, c.id DESC", "(c.created_at, c.id) < ($4, $5)"},
+	CommentSortTop:    {"rc.reply_count DESC, c.created_at DESC, c.id DESC", "(rc.reply_count, c.created_at, c.id) < ($7, $4, $5)"},
+}
+
+// GetPage returns a page of the top-level comments of the post, or of the replies to parentID when it is
+// not nil, with the number of their replies, and the cursor of the next page, nil on the last one.
+// The comments of users in a block with viewerID are left out.
+func (s *CommentStore) GetPage(ctx context.Context, postID int64, parentID *int64, viewerID int64, paginatedQuery *PaginatedCommentQuery) ([]*Comment, *Cursor, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	order := commentOrders[paginatedQuery.Sort]
+	query := `
+SELECT ` + commentColumns + `, rc.reply_count
+FROM comments c
+JOIN users u ON u.id = c.user_id
+CROSS JOIN LATERAL (
+    SELECT count(*) AS reply_count FROM comments r WHERE r.parent_id = c.id AND ` + replyVisibleToViewer + `
+) rc
+WHERE c.post_id = $1
+AND c.parent_id IS NOT DISTINCT FROM $3
+AND ` + visibleToViewer + `
+AND ($4::timestamp IS NULL OR ` + order.after + `)
+ORDER BY ` + order.orderBy + `
+LIMIT $6`
+	cursorCreatedAt, cursorID := paginatedQuery.Cursor.args()
+	// one extra row tells whether there is a next page
+	args := []any{postID, viewerID, parentID, cursorCreatedAt, cursorID, paginatedQuery.Limit + 1}
+	if paginatedQuery.Sort == CommentSortTop {
+		var cursorScore any
+		if paginatedQuery.Cursor != nil {
+			cursorScore = paginatedQuery.Cursor.Score
+		}
+		args = append(args, cursorScore)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	comments := make([]*Comment, 0, paginatedQuery.Limit)
+	for rows.Next() {
+		var replyCount int
+		comment, err := scanComment(rows, &replyCount)
+		if err != nil {
+			return nil, nil, err
+		}
+		comment.ReplyCount = replyCount
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(comments) <= paginatedQuery.Limit {
+		return comments, nil, nil
+	}
+	comments = comments[:paginatedQuery.Limit]
+	last := comments[len(comments)-1]
+	next := &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	if paginatedQuery.Sort == CommentSortTop {
+		next.Score = int64(last.ReplyCount)
+	}
+	return comments, next, nil
+}
+
+// CountByPostID returns the number of the comments of the post, replies included, leaving out tombstones
+// and the comments of users in a block with viewerID.
+func (s *CommentStore) CountByPostID(ctx context.Context, postID, viewerID int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT count(*) FROM comments c
+WHERE c.post_id = $1 AND c.deleted_at IS NULL
+AND ` + visibleToViewer
+	var count int
+	err := s.db.QueryRowContext(ctx, query, postID, viewerID).Scan(&count)
+	return count, err
+}
+
+// replyVisibleToViewer is visibleToViewer for replies aliased r.
+const replyVisibleToViewer = `NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.user_id = $2 AND b.blocked_id = r.user_id) OR (b.user_id = r.user_id AND b.blocked_id = $2)
+)`
+
+// GetByUserID returns the comments written by the user, newest first.
+func (s *CommentStore) GetByUserID(ctx context.Context, userID int64) ([]*Comment, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT ` + commentColumns + `
+FROM comments c
+JOIN users u ON u.id = c.user_id
+WHERE c.user_id = $1 AND c.deleted_at IS NULL
+ORDER BY c.created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	comments := []*Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
 			return nil, err
 		}
-		comments = append(comments, &comment)
+		comments = append(comments, comment)
 	}
 
-	return comments, nil
+	return comments, rows.Err()
 }
 
+// Create adds the comment, returning ErrBlocked if the author of the post and the commenter
+// are in a block and ErrNotFound if the parent comment has been deleted meanwhile.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
 	query := `
-INSERT INTO comments (post_id, user_id, content) VALUES ($1, $2, $3)
+INSERT INTO comments (post_id, user_id, content, parent_id, depth)
+SELECT p.id, $2, $3, $4, $5 FROM posts p
+WHERE p.id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.user_id = p.user_id AND b.blocked_id = $2) OR (b.user_id = $2 AND b.blocked_id = p.user_id)
+)
 RETURNING id, created_at, updated_at`
-	return s.db.QueryRowContext(
+	err := s.db.QueryRowContext(
 		ctx,
 		query,
 		comment.PostID,
 		comment.User.ID,
 		comment.Content,
+		comment.ParentID,
+		comment.Depth,
 	).Scan(
 		&comment.ID,
 		&comment.CreatedAt,
 		&comment.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrBlocked
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Update saves the content of the comment if it has not been changed since it was read and records
+// the previous content as an edit by editorID. It returns ErrNotFound if the comment has been
+// changed or deleted meanwhile.
+func (s *CommentStore) Update(ctx context.Context, comment *Comment, editorID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	return withTrx(ctx, s.db, func(tx *sql.Tx) error {
+		query := `
+INSERT INTO comment_edits (comment_id, content, version, edited_by)
+SELECT id, content, version, $3 FROM comments
+WHERE id = $1 AND version = $2 AND deleted_at IS NULL`
+		res, err := tx.ExecContext(ctx, query, comment.ID, comment.Version, editorID)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			return ErrNotFound
+		}
+		query = `
+UPDATE comments SET content = $1, updated_at = NOW(), version = version + 1
+WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+RETURNING version, updated_at`
+		err = tx.QueryRowContext(ctx, query, comment.Content, comment.ID, comment.Version).Scan(&comment.Version, &comment.UpdatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	})
+}
+
+// GetEdits returns the edits of the comment, newest first.
+func (s *CommentStore) GetEdits(ctx context.Context, commentID int64) ([]*CommentEdit, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT id, comment_id, content, version, edited_by, created_at
+FROM comment_edits
+WHERE comment_id = $1
+ORDER BY version DESC`
+	rows, err := s.db.QueryContext(ctx, query, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	edits := []*CommentEdit{}
+	for rows.Next() {
+		edit := &CommentEdit{}
+		if err := rows.Scan(&edit.ID, &edit.CommentID, &edit.Content, &edit.Version, &edit.EditedBy, &edit.CreatedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
+
+// Delete deletes the comment, returning ErrNotFound if there is no such comment. A comment with replies
+// becomes a tombstone instead, and tombstones left without replies are deleted up the thread.
+func (s *CommentStore) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	return withTrx(ctx, s.db, func(tx *sql.Tx) error {
+		var parentID sql.NullInt64
+		var hasReplies bool
+		err := tx.QueryRowContext(ctx, `
+SELECT c.parent_id, EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+FROM comments c WHERE c.id = $1 AND c.deleted_at IS NULL
+FOR UPDATE`, id).Scan(&parentID, &hasReplies)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if hasReplies {
+			_, err := tx.ExecContext(ctx, `UPDATE comments SET content = '', deleted_at = NOW() WHERE id = $1`, id)
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+			return err
+		}
+		for parentID.Valid {
+			err := tx.QueryRowContext(ctx, `
+DELETE FROM comments c
+WHERE c.id = $1 AND c.deleted_at IS NOT NULL
+AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+RETURNING c.parent_id`, parentID.Int64).Scan(&parentID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCommentTree(t *testing.T) {
+	parentOf := func(id int64) *int64 { return &id }
+	roots := CommentTree([]*Comment{
+		{ID: 1},
+		{ID: 2, ParentID: parentOf(1)},
+		{ID: 3},
+		{ID: 4, ParentID: parentOf(2)},
+		{ID: 5, ParentID: parentOf(1)},
+		// the parent is hidden from the viewer
+		{ID: 6, ParentID: parentOf(99)},
+	})
+	if len(roots) != 2 || roots[0].ID != 1 || roots[1].ID != 3 {
+		t.Fatalf("unexpected top-level comments %v", roots)
+	}
+	replies := roots[0].Replies
+	if len(replies) != 2 || replies[0].ID != 2 || replies[1].ID != 5 || roots[0].ReplyCount != 2 {
+		t.Fatalf("unexpected replies %v", replies)
+	}
+	if len(replies[0].Replies) != 1 || replies[0].Replies[0].ID != 4 {
+		t.Fatalf("unexpected nested replies %v", replies[0].Replies)
+	}
+}
+
+func TestCommentThreads(t *testing.T) {
+	db := newTestDB(t)
+	s := &CommentStore{db: db}
+	ctx := context.Background()
+
+	author := createTestUser(t, db, "author")
+	alice := createTestUser(t, db, "alice")
+	var postID int64
+	if err := db.QueryRow(`INSERT INTO posts (title, content, user_id) VALUES ('title', 'content', $1) RETURNING id`, author).Scan(&postID); err != nil {
+		t.Fatal(err)
+	}
+	create := func(userID int64, parent *Comment) *Comment {
+		t.Helper()
+		comment := &Comment{PostID: postID, User: &User{ID: userID}, Content: "comment"}
+		if parent != nil {
+			comment.ParentID = &parent.ID
+			comment.Depth = parent.Depth + 1
+		}
+		if err := s.Create(ctx, comment); err != nil {
+			t.Fatal(err)
+		}
+		return comment
+	}
+	first := create(alice, nil)
+	reply := create(author, first)
+	nested := create(alice, reply)
+	second := create(author, nil)
+
+	page, next, err := s.GetPage(ctx, postID, nil, author, &PaginatedCommentQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != first.ID || page[0].ReplyCount != 1 || next == nil {
+		t.Fatalf("unexpected first page %v, cursor %v", page, next)
+	}
+	page, next, err = s.GetPage(ctx, postID, nil, author, &PaginatedCommentQuery{Limit: 1, Cursor: next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != second.ID || next != nil {
+		t.Fatalf("unexpected last page %v, cursor %v", page, next)
+	}
+	page, _, err = s.GetPage(ctx, postID, &reply.ID, author, &PaginatedCommentQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != nested.ID || page[0].Depth != 2 {
+		t.Fatalf("unexpected replies %v", page)
+	}
+	thread, err := s.GetReplies(ctx, postID, []int64{first.ID, second.ID}, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread) != 2 || thread[0].ID != reply.ID || thread[1].ID != nested.ID {
+		t.Fatalf("expected the replies under the first comment, got %v", thread)
+	}
+
+	page, _, err = s.GetPage(ctx, postID, nil, author, &PaginatedCommentQuery{Limit: 10, Sort: CommentSortNewest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].ID != second.ID || page[1].ID != first.ID {
+		t.Fatalf("expected the newest comment first, got %v", page)
+	}
+	page, next, err = s.GetPage(ctx, postID, nil, author, &PaginatedCommentQuery{Limit: 1, Sort: CommentSortTop})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != first.ID || next == nil || next.Score != 1 {
+		t.Fatalf("expected the comment with replies first, got %v, cursor %+v", page, next)
+	}
+	page, next, err = s.GetPage(ctx, postID, nil, author, &PaginatedCommentQuery{Limit: 1, Sort: CommentSortTop, Cursor: next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != second.ID || next != nil {
+		t.Fatalf("unexpected last page of the top comments %v, cursor %+v", page, next)
+	}
+	if count, err := s.CountByPostID(ctx, postID, author); err != nil || count != 4 {
+		t.Fatalf("expected 4 comments, got %d, %v", count, err)
+	}
+
+	// a comment with replies becomes a tombstone
+	if err := s.Delete(ctx, reply.ID); err != nil {
+		t.Fatal(err)
+	}
+	tombstone, err := s.GetByID(ctx, reply.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tombstone.DeletedAt == nil || tombstone.Content != "" || tombstone.User != nil {
+		t.Fatalf("expected a tombstone, got %+v", tombstone)
+	}
+	if err := s.Delete(ctx, reply.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// deleting the last reply removes the tombstone above it
+	if err := s.Delete(ctx, nested.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetByID(ctx, reply.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the tombstone to be removed, got %v", err)
+	}
+	if _, err := s.GetByID(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parent is gone by the time the reply is saved
+	orphan := &Comment{PostID: postID, User: &User{ID: alice}, Content: "reply", ParentID: &nested.ID, Depth: 3}
+	if err := s.Create(ctx, orphan); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCommentEdits(t *testing.T) {
+	db := newTestDB(t)
+	s := &CommentStore{db: db}
+	ctx := context.Background()
+
+	alice := createTestUser(t, db, "alice")
+	moderator := createTestUser(t, db, "moderator")
+	var postID int64
+	if err := db.QueryRow(`INSERT INTO posts (title, content, user_id) VALUES ('title', 'content', $1) RETURNING id`, alice).Scan(&postID); err != nil {
+		t.Fatal(err)
+	}
+	comment := &Comment{PostID: postID, User: &User{ID: alice}, Content: "first"}
+	if err := s.Create(ctx, comment); err != nil {
+		t.Fatal(err)
+	}
+	stale := *comment
+
+	comment.Content = "second"
+	if err := s.Update(ctx, comment, alice); err != nil {
+		t.Fatal(err)
+	}
+	if comment.Version != 1 || !comment.UpdatedAt.Valid {
+		t.Fatalf("expected version 1 with updated_at, got %+v", comment)
+	}
+	stale.Content = "lost"
+	if err := s.Update(ctx, &stale, moderator); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a stale version, got %v", err)
+	}
+	comment.Content = "third"
+	if err := s.Update(ctx, comment, moderator); err != nil {
+		t.Fatal(err)
+	}
+
+	edits, err := s.GetEdits(ctx, comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 2 {
+		t.Fatalf("expected 2 edits, got %d", len(edits))
+	}
+	if edits[0].Content != "second" || edits[0].EditedBy != moderator || edits[1].Content != "first" || edits[1].EditedBy != alice {
+		t.Fatalf("unexpected edits %+v %+v", edits[0], edits[1])
+	}
+	saved, err := s.GetByID(ctx, comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Content != "third" || saved.Version != 2 {
+		t.Fatalf("unexpected comment %+v", saved)
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// newTestDB connects to the Postgres of TEST_DB_ADDR and migrates a schema of its own, dropped when
+// the test ends. Tests using it are skipped when TEST_DB_ADDR is not set.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		t.Skip("TEST_DB_ADDR is not set")
+	}
+
+	admin, err := sql.Open("pgx", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	dsn, err := url.Parse(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := dsn.Query()
+	// extensions created before stay in public
+	params.Set("search_path", schema+",public")
+	// comments.created_at has no time zone, keep it comparable with the posts
+	params.Set("timezone", "UTC")
+	dsn.RawQuery = params.Encode()
+	db, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("../../cmd/migrate/migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, migration := range migrations {
+		statements, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(statements)); err != nil {
+			t.Fatalf("migration %s failed: %v", filepath.Base(migration), err)
+		}
+	}
+	return db
+}
+
+func createTestUser(t *testing.T, db *sql.DB, username string) int64 {
+	t.Helper()
+	query := `
+INSERT INTO users (username, email, password, active, role_id)
+VALUES ($1, $1 || '@example.com', '', TRUE, (SELECT id FROM roles WHERE name = 'user'))
+RETURNING id`
+	var id int64
+	if err := db.QueryRowContext(context.Background(), query, username).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
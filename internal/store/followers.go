@@ -4,43 +4,283 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// FollowUser is a user in a followers or following list.
+type FollowUser struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	DisplayName  string    `json:"display_name,omitempty"`
+	AvatarURL    string    `json:"avatar_url,omitempty"`
+	FollowedAt   time.Time `json:"followed_at"`
+	FollowedByMe bool      `json:"followed_by_me"`
+}
+
 type FollowerStore struct {
 	db *sql.DB
 }
 
-func (s *FollowerStore) Follow(ctx context.Context, user *User, follower *User) error {
+const (
+	FollowStatusPending  = "pending"
+	FollowStatusAccepted = "accepted"
+)
+
+var ErrSelfFollow = errors.New("users cannot follow themselves")
+
+// Follow makes follower follow the user, or requests to when the account of the user is private.
+// It returns the status of the relationship, ErrSelfFollow if the users are the same, ErrBlocked
+// if they are in a block and ErrNotFound if either of them does not exist.
+func (s *FollowerStore) Follow(ctx context.Context, user *User, follower *User) (string, error) {
+	if user.ID == follower.ID {
+		return "", ErrSelfFollow
+	}
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
+	status := FollowStatusAccepted
+	if user.IsPrivate {
+		status = FollowStatusPending
+	}
 	query := `
-INSERT INTO user_follower (user_id, follower_id) VALUES ($1, $2)`
-	_, err := s.db.ExecContext(
+INSERT INTO user_follower (user_id, follower_id, status)
+SELECT $1, $2, $3
+WHERE NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_id = $1 AND blocked_id = $2) OR (user_id = $2 AND blocked_id = $1)
+)`
+	res, err := s.db.ExecContext(
 		ctx,
 		query,
 		user.ID,
-		follower.ID)
+		follower.ID,
+		status)
 	if err != nil {
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			return ErrConflict
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return "", ErrConflict
+			case "23503":
+				return "", ErrNotFound
+			case "23514":
+				return "", ErrSelfFollow
+			}
 		}
-		return err
+		return "", err
 	}
-	return nil
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if affected == 0 {
+		return "", ErrBlocked
+	}
+	return status, nil
 }
 
+// Unfollow removes the follow relationship, withdrawing the request if it is still pending.
+// It returns ErrNotFound if follower does not follow the user.
 func (s *FollowerStore) Unfollow(ctx context.Context, user *User, follower *User) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
 	query := `
 DELETE FROM user_follower WHERE user_id = $1 AND follower_id = $2`
-	_, err := s.db.ExecContext(
+	res, err := s.db.ExecContext(
 		ctx,
 		query,
 		user.ID,
 		follower.ID)
-	return err
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FollowStats are the follower counts of a user as seen by a viewer.
+type FollowStats struct {
+	FollowersCount  int  `json:"followers_count"`
+	FollowingCount  int  `json:"following_count"`
+	FollowedByMe    bool `json:"followed_by_me"`
+	FollowRequested bool `json:"follow_requested"`
+}
+
+// GetStats returns the follower and following counts of the user and whether viewerID follows the user
+// or has requested to.
+func (s *FollowerStore) GetStats(ctx context.Context, userID, viewerID int64) (*FollowStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT (SELECT count(*) FROM user_follower WHERE user_id = $1 AND status = 'accepted'),
+       (SELECT count(*) FROM user_follower WHERE follower_id = $1 AND status = 'accepted'),
+       EXISTS (SELECT 1 FROM user_follower WHERE user_id = $1 AND follower_id = $2 AND status = 'accepted'),
+       EXISTS (SELECT 1 FROM user_follower WHERE user_id = $1 AND follower_id = $2 AND status = 'pending')`
+	stats := &FollowStats{}
+	err := s.db.QueryRowContext(ctx, query, userID, viewerID).Scan(
+		&stats.FollowersCount,
+		&stats.FollowingCount,
+		&stats.FollowedByMe,
+		&stats.FollowRequested)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetFollowers returns a page of the users following the user, most recent first,
+// and the cursor of the next page, nil on the last one.
+func (s *FollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, paginatedQuery *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error) {
+	query := `
+SELECT u.id, u.username, u.display_name, u.avatar_url, uf.created_at,
+       EXISTS (SELECT 1 FROM user_follower me WHERE me.user_id = u.id AND me.follower_id = $2 AND me.status = 'accepted')
+FROM user_follower uf
+JOIN users u ON u.id = uf.follower_id
+WHERE uf.user_id = $1 AND uf.status = 'accepted' AND u.deleted_at IS NULL
+AND ($3::timestamptz IS NULL OR (uf.created_at, uf.follower_id) < ($3, $4))
+ORDER BY uf.created_at DESC, uf.follower_id DESC
+LIMIT $5`
+	return s.getFollowUsers(ctx, query, userID, viewerID, paginatedQuery)
+}
+
+// GetFollowing returns a page of the users the user follows, most recent first,
+// and the cursor of the next page, nil on the last one.
+func (s *FollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, paginatedQuery *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error) {
+	query := `
+SELECT u.id, u.username, u.display_name, u.avatar_url, uf.created_at,
+       EXISTS (SELECT 1 FROM user_follower me WHERE me.user_id = u.id AND me.follower_id = $2 AND me.status = 'accepted')
+FROM user_follower uf
+JOIN users u ON u.id = uf.user_id
+WHERE uf.follower_id = $1 AND uf.status = 'accepted' AND u.deleted_at IS NULL
+AND ($3::timestamptz IS NULL OR (uf.created_at, uf.user_id) < ($3, $4))
+ORDER BY uf.created_at DESC, uf.user_id DESC
+LIMIT $5`
+	return s.getFollowUsers(ctx, query, userID, viewerID, paginatedQuery)
+}
+
+// GetFollowRequests returns a page of the pending requests to follow the user, most recent first,
+// and the cursor of the next page, nil on the last one.
+func (s *FollowerStore) GetFollowRequests(ctx context.Context, userID int64, paginatedQuery *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error) {
+	query := `
+SELECT u.id, u.username, u.display_name, u.avatar_url, uf.created_at,
+       EXISTS (SELECT 1 FROM user_follower me WHERE me.user_id = u.id AND me.follower_id = $2 AND me.status = 'accepted')
+FROM user_follower uf
+JOIN users u ON u.id = uf.follower_id
+WHERE uf.user_id = $1 AND uf.status = 'pending' AND u.deleted_at IS NULL
+AND ($3::timestamptz IS NULL OR (uf.created_at, uf.follower_id) < ($3, $4))
+ORDER BY uf.created_at DESC, uf.follower_id DESC
+LIMIT $5`
+	return s.getFollowUsers(ctx, query, userID, userID, paginatedQuery)
+}
+
+// AcceptFollowRequest accepts the pending request of follower to follow the user,
+// returning ErrNotFound if there is no such request.
+func (s *FollowerStore) AcceptFollowRequest(ctx context.Context, userID, followerID int64) error {
+	query := `
+UPDATE user_follower SET status = 'accepted', created_at = NOW()
+WHERE user_id = $1 AND follower_id = $2 AND status = 'pending'`
+	return s.execFollowRequest(ctx, query, userID, followerID)
+}
+
+// RejectFollowRequest deletes the pending request of follower to follow the user,
+// returning ErrNotFound if there is no such request.
+func (s *FollowerStore) RejectFollowRequest(ctx context.Context, userID, followerID int64) error {
+	query := `
+DELETE FROM user_follower WHERE user_id = $1 AND follower_id = $2 AND status = 'pending'`
+	return s.execFollowRequest(ctx, query, userID, followerID)
+}
+
+func (s *FollowerStore) execFollowRequest(ctx context.Context, query string, userID, followerID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, query, userID, followerID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IsFollowing reports whether follower follows the user with an accepted relationship.
+func (s *FollowerStore) IsFollowing(ctx context.Context, userID, followerID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT EXISTS (SELECT 1 FROM user_follower WHERE user_id = $1 AND follower_id = $2 AND status = 'accepted')`
+	var following bool
+	if err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following); err != nil {
+		return false, err
+	}
+	return following, nil
+}
+
+// GetFollowerIDs returns the IDs of the users following the user.
+func (s *FollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT follower_id FROM user_follower WHERE user_id = $1 AND status = 'accepted'`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *FollowerStore) getFollowUsers(ctx context.Context, query string, userID, viewerID int64, paginatedQuery *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	cursorCreatedAt, cursorID := paginatedQuery.cursorArgs()
+	// one extra row tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, userID, viewerID, cursorCreatedAt, cursorID, paginatedQuery.Limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	users := make([]*FollowUser, 0, paginatedQuery.Limit)
+	for rows.Next() {
+		user := &FollowUser{}
+		if err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.DisplayName,
+			&user.AvatarURL,
+			&user.FollowedAt,
+			&user.FollowedByMe,
+		); err != nil {
+			return nil, nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(users) <= paginatedQuery.Limit {
+		return users, nil, nil
+	}
+	users = users[:paginatedQuery.Limit]
+	last := users[len(users)-1]
+	return users, &Cursor{CreatedAt: last.FollowedAt, ID: last.ID}, nil
 }
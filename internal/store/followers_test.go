@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFollowRejectsSelfFollow(t *testing.T) {
+	s := &FollowerStore{}
+	user := &User{ID: 1}
+
+	// the check runs before any query, so the store needs no database
+	status, err := s.Follow(context.Background(), user, &User{ID: 1})
+	if !errors.Is(err, ErrSelfFollow) {
+		t.Fatalf("expected ErrSelfFollow, got %v", err)
+	}
+	if status != "" {
+		t.Fatalf("expected no status, got %q", status)
+	}
+}
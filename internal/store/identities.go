@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// IdentityStore links users to accounts at external OpenID Connect providers.
+type IdentityStore struct {
+	db *sql.DB
+}
+
+func (s *IdentityStore) GetUserID(ctx context.Context, provider, subject string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`
+	var userID int64
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+func (s *IdentityStore) Link(ctx context.Context, userID int64, provider, subject string) error {
+	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		return s.link(ctx, trx, userID, provider, subject)
+	})
+}
+
+// CreateUser registers a new user signed in through the provider and links the identity to it.
+func (s *IdentityStore) CreateUser(ctx context.Context, user *User, provider, subject string) error {
+	users := &UserStore{db: s.db}
+	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		if err := users.create(ctx, trx, user); err != nil {
+			return err
+		}
+		return s.link(ctx, trx, user.ID, provider, subject)
+	})
+}
+
+func (s *IdentityStore) link(ctx context.Context, trx *sql.Tx, userID int64, provider, subject string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `INSERT INTO user_identities (provider, subject, user_id) VALUES ($1, $2, $3)`
+	_, err := trx.ExecContext(ctx, query, provider, subject, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
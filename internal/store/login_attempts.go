@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	LoginScopeEmail = "email"
+	LoginScopeIP    = "ip"
+	// LoginScopeMFA counts the wrong codes sent for a two-factor challenge, keyed by the jti of the challenge.
+	LoginScopeMFA = "mfa"
+)
+
+// LoginAttempt holds the failed logins for an email or a client IP.
+type LoginAttempt struct {
+	Scope        string     `json:"scope"`
+	Key          string     `json:"key"`
+	Failures     int        `json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
+
+type LoginAttemptStore struct {
+	db *sql.DB
+}
+
+func (s *LoginAttemptStore) Get(ctx context.Context, scope, key string) (*LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `SELECT scope, key, failures, last_failed_at, locked_until FROM login_attempts WHERE scope = $1 AND key = $2`
+	attempt := &LoginAttempt{}
+	err := s.db.QueryRowContext(ctx, query, scope, key).Scan(
+		&attempt.Scope,
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailedAt,
+		&attempt.LockedUntil,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return attempt, nil
+}
+
+// RecordFailure counts a failed login. Failures older than window are forgotten,
+// so the count starts over once the last failure is that far in the past.
+func (s *LoginAttemptStore) RecordFailure(ctx context.Context, scope, key string, window time.Duration) (*LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO login_attempts (scope, key, failures, last_failed_at) VALUES ($1, $2, 1, NOW())
+ON CONFLICT (scope, key) DO UPDATE SET
+	failures = CASE
+		WHEN login_attempts.last_failed_at < NOW() - make_interval(secs => $3) THEN 1
+		ELSE login_attempts.failures + 1
+	END,
+	last_failed_at = EXCLUDED.last_failed_at
+RETURNING scope, key, failures, last_failed_at, locked_until`
+	attempt := &LoginAttempt{}
+	err := s.db.QueryRowContext(ctx, query, scope, key, window.Seconds()).Scan(
+		&attempt.Scope,
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailedAt,
+		&attempt.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (s *LoginAttemptStore) Lock(ctx context.Context, scope, key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `UPDATE login_attempts SET locked_until = $3 WHERE scope = $1 AND key = $2`
+	_, err := s.db.ExecContext(ctx, query, scope, key, until)
+	return err
+}
+
+// Reset forgets the failed logins and lifts the lockout, returning ErrNotFound if there were none.
+func (s *LoginAttemptStore) Reset(ctx context.Context, scope, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM login_attempts WHERE scope = $1 AND key = $2`
+	res, err := s.db.ExecContext(ctx, query, scope, key)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetLocked returns the emails and IPs that are locked out at the moment.
+func (s *LoginAttemptStore) GetLocked(ctx context.Context) ([]*LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT scope, key, failures, last_failed_at, locked_until FROM login_attempts
+WHERE locked_until > NOW()
+ORDER BY locked_until DESC`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*LoginAttempt
+	for rows.Next() {
+		attempt := &LoginAttempt{}
+		err := rows.Scan(
+			&attempt.Scope,
+			&attempt.Key,
+			&attempt.Failures,
+			&attempt.LastFailedAt,
+			&attempt.LockedUntil,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type MFAStore struct {
+	db *sql.DB
+}
+
+// SetSecret stores a TOTP secret for the user. The secret is not enforced until Enable is called.
+func (s *MFAStore) SetSecret(ctx context.Context, userID int64, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `UPDATE users SET totp_secret = $1 WHERE id = $2 AND mfa_enabled = FALSE`
+	res, err := s.db.ExecContext(ctx, query, secret, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *MFAStore) GetSecret(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `SELECT totp_secret FROM users WHERE id = $1`
+	var secret sql.NullString
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+	if !secret.Valid {
+		return "", ErrNotFound
+	}
+	return secret.String, nil
+}
+
+// UseTOTPStep records that a TOTP code of the time step was accepted for the user, returning ErrConflict
+// if a code of the same or a later step has already been accepted.
+func (s *MFAStore) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+UPDATE users SET totp_last_step = $2
+WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Enable turns on two-factor authentication for the user and replaces the recovery codes.
+func (s *MFAStore) Enable(ctx context.Context, userID int64, recoveryCodeHashes []string) error {
+	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		if err := s.enable(ctx, trx, userID); err != nil {
+			return err
+		}
+		if err := s.deleteRecoveryCodes(ctx, trx, userID); err != nil {
+			return err
+		}
+		for _, codeHash := range recoveryCodeHashes {
+			if err := s.createRecoveryCode(ctx, trx, userID, codeHash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MFAStore) enable(ctx context.Context, tx *sql.Tx, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `UPDATE users SET mfa_enabled = TRUE WHERE id = $1 AND totp_secret IS NOT NULL`
+	res, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MFAStore) deleteRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM user_recovery_codes WHERE user_id = $1`
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (s *MFAStore) createRecoveryCode(ctx context.Context, tx *sql.Tx, userID int64, codeHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+	_, err := tx.ExecContext(ctx, query, userID, codeHash)
+	return err
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used, returning ErrNotFound if there is none.
+func (s *MFAStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+UPDATE user_recovery_codes SET used_at = NOW()
+WHERE id = (
+	SELECT id FROM user_recovery_codes
+	WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	LIMIT 1
+)`
+	res, err := s.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
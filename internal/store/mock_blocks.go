@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockBlockStore struct {
+	mock.Mock
+}
+
+func (m *MockBlockStore) Block(ctx context.Context, userID, blockedID int64) error {
+	args := m.Called(ctx, userID, blockedID)
+	return args.Error(0)
+}
+
+func (m *MockBlockStore) Unblock(ctx context.Context, userID, blockedID int64) error {
+	args := m.Called(ctx, userID, blockedID)
+	return args.Error(0)
+}
+
+func (m *MockBlockStore) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	args := m.Called(ctx, userID, otherID)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCommentStore struct {
+	mock.Mock
+}
+
+func (m *MockCommentStore) GetReplies(ctx context.Context, postID int64, parentIDs []int64, viewerID int64) ([]*Comment, error) {
+	args := m.Called(ctx, postID, parentIDs, viewerID)
+	comments, _ := args.Get(0).([]*Comment)
+	return comments, args.Error(1)
+}
+
+func (m *MockCommentStore) GetByUserID(ctx context.Context, userID int64) ([]*Comment, error) {
+	args := m.Called(ctx, userID)
+	comments, _ := args.Get(0).([]*Comment)
+	return comments, args.Error(1)
+}
+
+func (m *MockCommentStore) Create(ctx context.Context, comment *Comment) error {
+	args := m.Called(ctx, comment)
+	return args.Error(0)
+}
+
+func (m *MockCommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	args := m.Called(ctx, id)
+	comment, _ := args.Get(0).(*Comment)
+	return comment, args.Error(1)
+}
+
+func (m *MockCommentStore) GetPage(ctx context.Context, postID int64, parentID *int64, viewerID int64, query *PaginatedCommentQuery) ([]*Comment, *Cursor, error) {
+	args := m.Called(ctx, postID, parentID, viewerID, query)
+	comments, _ := args.Get(0).([]*Comment)
+	cursor, _ := args.Get(1).(*Cursor)
+	return comments, cursor, args.Error(2)
+}
+
+func (m *MockCommentStore) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockCommentStore) Update(ctx context.Context, comment *Comment, editorID int64) error {
+	args := m.Called(ctx, comment, editorID)
+	return args.Error(0)
+}
+
+func (m *MockCommentStore) GetEdits(ctx context.Context, commentID int64) ([]*CommentEdit, error) {
+	args := m.Called(ctx, commentID)
+	edits, _ := args.Get(0).([]*CommentEdit)
+	return edits, args.Error(1)
+}
+
+func (m *MockCommentStore) CountByPostID(ctx context.Context, postID, viewerID int64) (int, error) {
+	args := m.Called(ctx, postID, viewerID)
+	return args.Int(0), args.Error(1)
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFollowerStore struct {
+	mock.Mock
+}
+
+func (m *MockFollowerStore) Follow(ctx context.Context, user *User, follower *User) (string, error) {
+	args := m.Called(ctx, user, follower)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockFollowerStore) Unfollow(ctx context.Context, user *User, follower *User) error {
+	args := m.Called(ctx, user, follower)
+	return args.Error(0)
+}
+
+func (m *MockFollowerStore) GetStats(ctx context.Context, userID, viewerID int64) (*FollowStats, error) {
+	args := m.Called(ctx, userID, viewerID)
+	stats, _ := args.Get(0).(*FollowStats)
+	return stats, args.Error(1)
+}
+
+func (m *MockFollowerStore) GetFollowers(ctx context.Context, userID, viewerID int64, query *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error) {
+	args := m.Called(ctx, userID, viewerID, query)
+	users, _ := args.Get(0).([]*FollowUser)
+	next, _ := args.Get(1).(*Cursor)
+	return users, next, args.Error(2)
+}
+
+func (m *MockFollowerStore) GetFollowing(ctx context.Context, userID, viewerID int64, query *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error) {
+	args := m.Called(ctx, userID, viewerID, query)
+	users, _ := args.Get(0).([]*FollowUser)
+	next, _ := args.Get(1).(*Cursor)
+	return users, next, args.Error(2)
+}
+
+func (m *MockFollowerStore) GetFollowRequests(ctx context.Context, userID int64, query *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error) {
+	args := m.Called(ctx, userID, query)
+	users, _ := args.Get(0).([]*FollowUser)
+	next, _ := args.Get(1).(*Cursor)
+	return users, next, args.Error(2)
+}
+
+func (m *MockFollowerStore) AcceptFollowRequest(ctx context.Context, userID, followerID int64) error {
+	args := m.Called(ctx, userID, followerID)
+	return args.Error(0)
+}
+
+func (m *MockFollowerStore) RejectFollowRequest(ctx context.Context, userID, followerID int64) error {
+	args := m.Called(ctx, userID, followerID)
+	return args.Error(0)
+}
+
+func (m *MockFollowerStore) IsFollowing(ctx context.Context, userID, followerID int64) (bool, error) {
+	args := m.Called(ctx, userID, followerID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockFollowerStore) GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error) {
+	args := m.Called(ctx, userID)
+	ids, _ := args.Get(0).([]int64)
+	return ids, args.Error(1)
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdentityStore struct {
+	mock.Mock
+}
+
+func (m *MockIdentityStore) GetUserID(ctx context.Context, provider, subject string) (int64, error) {
+	args := m.Called(ctx, provider, subject)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockIdentityStore) Link(ctx context.Context, userID int64, provider, subject string) error {
+	args := m.Called(ctx, userID, provider, subject)
+	return args.Error(0)
+}
+
+func (m *MockIdentityStore) CreateUser(ctx context.Context, user *User, provider, subject string) error {
+	args := m.Called(ctx, user, provider, subject)
+	return args.Error(0)
+}
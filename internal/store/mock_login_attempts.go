@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLoginAttemptStore struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptStore) Get(ctx context.Context, scope, key string) (*LoginAttempt, error) {
+	args := m.Called(ctx, scope, key)
+	attempt, _ := args.Get(0).(*LoginAttempt)
+	return attempt, args.Error(1)
+}
+
+func (m *MockLoginAttemptStore) RecordFailure(ctx context.Context, scope, key string, window time.Duration) (*LoginAttempt, error) {
+	args := m.Called(ctx, scope, key, window)
+	attempt, _ := args.Get(0).(*LoginAttempt)
+	return attempt, args.Error(1)
+}
+
+func (m *MockLoginAttemptStore) Lock(ctx context.Context, scope, key string, until time.Time) error {
+	args := m.Called(ctx, scope, key, until)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptStore) Reset(ctx context.Context, scope, key string) error {
+	args := m.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptStore) GetLocked(ctx context.Context) ([]*LoginAttempt, error) {
+	args := m.Called(ctx)
+	attempts, _ := args.Get(0).([]*LoginAttempt)
+	return attempts, args.Error(1)
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMFAStore struct {
+	mock.Mock
+}
+
+func (m *MockMFAStore) SetSecret(ctx context.Context, userID int64, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+func (m *MockMFAStore) GetSecret(ctx context.Context, userID int64) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockMFAStore) Enable(ctx context.Context, userID int64, recoveryCodeHashes []string) error {
+	args := m.Called(ctx, userID, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFAStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Error(0)
+}
+
+func (m *MockMFAStore) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	args := m.Called(ctx, userID, step)
+	return args.Error(0)
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMuteStore struct {
+	mock.Mock
+}
+
+func (m *MockMuteStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	args := m.Called(ctx, userID, mutedID)
+	return args.Error(0)
+}
+
+func (m *MockMuteStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	args := m.Called(ctx, userID, mutedID)
+	return args.Error(0)
+}
+
+func (m *MockMuteStore) GetMuterIDs(ctx context.Context, mutedID int64) ([]int64, error) {
+	args := m.Called(ctx, mutedID)
+	ids, _ := args.Get(0).([]int64)
+	return ids, args.Error(1)
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPersonalTokenStore struct {
+	mock.Mock
+}
+
+func (m *MockPersonalTokenStore) Create(ctx context.Context, token *PersonalToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockPersonalTokenStore) Use(ctx context.Context, tokenHash string) (*PersonalToken, error) {
+	args := m.Called(ctx, tokenHash)
+	token, _ := args.Get(0).(*PersonalToken)
+	return token, args.Error(1)
+}
+
+func (m *MockPersonalTokenStore) GetByUserID(ctx context.Context, userID int64) ([]*PersonalToken, error) {
+	args := m.Called(ctx, userID)
+	tokens, _ := args.Get(0).([]*PersonalToken)
+	return tokens, args.Error(1)
+}
+
+func (m *MockPersonalTokenStore) Delete(ctx context.Context, id, userID int64) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
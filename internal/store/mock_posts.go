@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPostStore struct {
+	mock.Mock
+}
+
+func (m *MockPostStore) Create(ctx context.Context, post *Post) error {
+	args := m.Called(ctx, post)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) Update(ctx context.Context, post *Post) error {
+	args := m.Called(ctx, post)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	args := m.Called(ctx, id)
+	post, _ := args.Get(0).(*Post)
+	return post, args.Error(1)
+}
+
+func (m *MockPostStore) DeleteByID(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) GetUserFeed(ctx context.Context, user *User, query *PaginatedFeedQuery) ([]*PostWithMetadata, *Cursor, error) {
+	args := m.Called(ctx, user, query)
+	feed, _ := args.Get(0).([]*PostWithMetadata)
+	next, _ := args.Get(1).(*Cursor)
+	return feed, next, args.Error(2)
+}
+
+func (m *MockPostStore) GetFeedCandidates(ctx context.Context, user *User, query *PaginatedFeedQuery, limit int) ([]*FeedCandidate, error) {
+	args := m.Called(ctx, user, query, limit)
+	candidates, _ := args.Get(0).([]*FeedCandidate)
+	return candidates, args.Error(1)
+}
+
+func (m *MockPostStore) GetByUserID(ctx context.Context, userID int64) ([]*Post, error) {
+	args := m.Called(ctx, userID)
+	posts, _ := args.Get(0).([]*Post)
+	return posts, args.Error(1)
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReactionStore struct {
+	mock.Mock
+}
+
+func (m *MockReactionStore) Set(ctx context.Context, postID, userID int64, reaction string) error {
+	args := m.Called(ctx, postID, userID, reaction)
+	return args.Error(0)
+}
+
+func (m *MockReactionStore) Delete(ctx context.Context, postID, userID int64) error {
+	args := m.Called(ctx, postID, userID)
+	return args.Error(0)
+}
+
+func (m *MockReactionStore) GetSummary(ctx context.Context, postID, viewerID int64) (*ReactionSummary, error) {
+	args := m.Called(ctx, postID, viewerID)
+	summary, _ := args.Get(0).(*ReactionSummary)
+	return summary, args.Error(1)
+}
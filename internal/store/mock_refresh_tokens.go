@@ -0,0 +1,31 @@
+package store
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRefreshTokenStore struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenStore) Rotate(ctx context.Context, tokenHash string, next *RefreshToken) error {
+	args := m.Called(ctx, tokenHash, next)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenStore) Revoke(ctx context.Context, tokenHash string) error {
+	args := m.Called(ctx, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRevokedTokenStore struct {
+	mock.Mock
+}
+
+func (m *MockRevokedTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevokedTokenStore) Claim(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	args := m.Called(ctx, jti, expiresAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRevokedTokenStore) RevokeUser(ctx context.Context, userID int64, expiresAt time.Time) error {
+	args := m.Called(ctx, userID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevokedTokenStore) IsRevoked(ctx context.Context, jti string, userID int64, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, jti, userID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
@@ -14,3 +14,9 @@ func (s *MockRoleStore) GetByName(ctx context.Context, name string) (*Role, erro
 	args := s.Called(ctx, name)
 	return args.Get(0).(*Role), args.Error(1)
 }
+
+func (s *MockRoleStore) RequireMFA(ctx context.Context, minLevel int) ([]int64, error) {
+	args := s.Called(ctx, minLevel)
+	userIDs, _ := args.Get(0).([]int64)
+	return userIDs, args.Error(1)
+}
@@ -2,7 +2,19 @@ package store
 
 func NewMockStore() *Storage {
 	return &Storage{
-		Users: &MockUserStore{},
-		Roles: &MockRoleStore{},
+		Posts:          &MockPostStore{},
+		Users:          &MockUserStore{},
+		Comments:       &MockCommentStore{},
+		Followers:      &MockFollowerStore{},
+		Roles:          &MockRoleStore{},
+		RefreshTokens:  &MockRefreshTokenStore{},
+		RevokedTokens:  &MockRevokedTokenStore{},
+		MFA:            &MockMFAStore{},
+		Identities:     &MockIdentityStore{},
+		LoginAttempts:  &MockLoginAttemptStore{},
+		PersonalTokens: &MockPersonalTokenStore{},
+		Blocks:         &MockBlockStore{},
+		Mutes:          &MockMuteStore{},
+		Reactions:      &MockReactionStore{},
 	}
 }
@@ -11,7 +11,7 @@ type MockUserStore struct {
 	mock.Mock
 }
 
-func (m *MockUserStore) Activate(ctx context.Context, code string) error {
+func (m *MockUserStore) Activate(ctx context.Context, code string) (int64, error) {
 	panic("unimplemented")
 }
 
@@ -21,9 +21,44 @@ func (m *MockUserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 }
 
 func (m *MockUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	panic("unimplemented")
+	args := m.Called(ctx, email)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*User), args.Error(1)
 }
 
 func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, baseURL string, activationTTL time.Duration) error {
 	panic("unimplemented")
 }
+
+func (m *MockUserStore) ReplaceInvitation(ctx context.Context, userID int64, inviteCode string, expirationTime time.Duration) error {
+	panic("unimplemented")
+}
+
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, expirationTime time.Duration) error {
+	args := m.Called(ctx, userID, token, expirationTime)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	args := m.Called(ctx, token, user)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) RequestEmailChange(ctx context.Context, userID int64, newEmail, inviteCode string, expirationTime time.Duration) error {
+	args := m.Called(ctx, userID, newEmail, inviteCode, expirationTime)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) Update(ctx context.Context, user *User) ([]int64, error) {
+	args := m.Called(ctx, user)
+	accepted, _ := args.Get(0).([]int64)
+	return accepted, args.Error(1)
+}
+
+func (m *MockUserStore) Delete(ctx context.Context, userID int64, policy string) error {
+	args := m.Called(ctx, userID, policy)
+	return args.Error(0)
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MuteStore keeps the users whose posts a user does not want in the feed.
+// Unlike a block, a mute is invisible to the muted user.
+type MuteStore struct {
+	db *sql.DB
+}
+
+func (s *MuteStore) Mute(ctx context.Context, userID, mutedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `INSERT INTO user_mutes (user_id, muted_id) VALUES ($1, $2)`
+	if _, err := s.db.ExecContext(ctx, query, userID, mutedID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// Unmute removes the mute, returning ErrNotFound if the user has not muted mutedID.
+func (s *MuteStore) Unmute(ctx context.Context, userID, mutedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM user_mutes WHERE user_id = $1 AND muted_id = $2`
+	res, err := s.db.ExecContext(ctx, query, userID, mutedID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetMuterIDs returns the IDs of the users who have muted the user.
+func (s *MuteStore) GetMuterIDs(ctx context.Context, mutedID int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `SELECT user_id FROM user_mutes WHERE muted_id = $1`
+	rows, err := s.db.QueryContext(ctx, query, mutedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
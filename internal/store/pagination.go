@@ -1,6 +1,9 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -8,16 +11,43 @@ import (
 	"time"
 )
 
+const (
+	FeedSortCreatedAt = "created_at"
+	FeedSortComments  = "comments"
+	FeedSortActivity  = "activity"
+)
+
+const (
+	FeedModeChronological = "chronological"
+	FeedModeRanked        = "ranked"
+)
+
 type PaginatedFeedQuery struct {
 	Limit  int       `json:"limit" validate:"gte=1,lte=100"`
 	Offset int       `json:"offset" validate:"gte=0"`
 	Sort   string    `json:"sort" validate:"omitempty,oneof=asc desc"`
+	SortBy string    `json:"sort_by" validate:"omitempty,oneof=created_at comments activity"`
+	Mode   string    `json:"mode" validate:"omitempty,oneof=chronological ranked"`
 	Tags   []string  `json:"tags" validate:"max=5"`
 	Search string    `json:"search" validate:"omitempty,max=50"`
 	Since  time.Time `json:"since"`
 	Until  time.Time `json:"until"`
+	// IncludeOwn adds the posts of the user to the posts of the followed users.
+	IncludeOwn bool `json:"include_own"`
+	// Cursor continues the feed after the last post of the previous page and takes the place of Offset.
+	Cursor *Cursor `json:"-"`
+	// Timeline takes the place of the follows of the user when the timeline of the user is precomputed.
+	Timeline *FeedTimeline `json:"-"`
 }
 
+var (
+	errCursorWithOffset       = errors.New("cursor and offset cannot be used together")
+	errCursorWithCommentsSort = errors.New("cursor cannot be used when sorting by comments, use offset")
+	errUntilBeforeSince       = errors.New("until must be after since")
+	errCursorWithRankedMode   = errors.New("cursor cannot be used with the ranked feed, use offset")
+	errSortWithRankedMode     = errors.New("the ranked feed is sorted by score")
+)
+
 func ParsePaginatedFeedQuery(r *http.Request) (*PaginatedFeedQuery, error) {
 	query := r.URL.Query()
 	limit, err := getDefaultQueryIntParam(&query, "limit", 10)
@@ -28,11 +58,15 @@ func ParsePaginatedFeedQuery(r *http.Request) (*PaginatedFeedQuery, error) {
 	if err != nil {
 		return nil, err
 	}
-	sort := query.Get("sort")
 	paginatedFeedQuery := PaginatedFeedQuery{
 		Limit:  limit,
 		Offset: offset,
-		Sort:   sort,
+		Sort:   query.Get("sort"),
+		SortBy: query.Get("sort_by"),
+		Mode:   query.Get("mode"),
+	}
+	if paginatedFeedQuery.Mode == FeedModeRanked && (paginatedFeedQuery.Sort != "" || paginatedFeedQuery.SortBy != "") {
+		return nil, errSortWithRankedMode
 	}
 
 	if tagsParam := query.Get("tags"); tagsParam != "" {
@@ -53,6 +87,29 @@ func ParsePaginatedFeedQuery(r *http.Request) (*PaginatedFeedQuery, error) {
 		}
 		paginatedFeedQuery.Until = until
 	}
+	if includeOwnParam := query.Get("include_own"); includeOwnParam != "" {
+		if paginatedFeedQuery.IncludeOwn, err = strconv.ParseBool(includeOwnParam); err != nil {
+			return nil, err
+		}
+	}
+	if !paginatedFeedQuery.Since.IsZero() && !paginatedFeedQuery.Until.IsZero() &&
+		!paginatedFeedQuery.Until.After(paginatedFeedQuery.Since) {
+		return nil, errUntilBeforeSince
+	}
+	if cursorParam := query.Get("cursor"); cursorParam != "" {
+		if paginatedFeedQuery.Offset > 0 {
+			return nil, errCursorWithOffset
+		}
+		if paginatedFeedQuery.SortBy == FeedSortComments {
+			return nil, errCursorWithCommentsSort
+		}
+		if paginatedFeedQuery.Mode == FeedModeRanked {
+			return nil, errCursorWithRankedMode
+		}
+		if paginatedFeedQuery.Cursor, err = DecodeCursor(cursorParam); err != nil {
+			return nil, err
+		}
+	}
 	return &paginatedFeedQuery, nil
 }
 
@@ -63,3 +120,109 @@ func getDefaultQueryIntParam(values *url.Values, key string, defaultValue int) (
 	}
 	return strconv.Atoi(urlParam)
 }
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last item of a page in a list ordered by creation time and ID,
+// so the next page starts right after it regardless of rows inserted meanwhile.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+	// Score is the value sorted on before the creation time in lists that are ranked, like the top comments.
+	Score int64
+}
+
+func (c *Cursor) Encode() string {
+	raw := fmt.Sprintf("%d,%d", c.CreatedAt.UnixNano(), c.ID)
+	if c.Score != 0 {
+		raw += fmt.Sprintf(",%d", c.Score)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ",")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursor := &Cursor{CreatedAt: time.Unix(0, nanos).UTC()}
+	if cursor.ID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if len(parts) == 3 {
+		if cursor.Score, err = strconv.ParseInt(parts[2], 10, 64); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return cursor, nil
+}
+
+type PaginatedCursorQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=100"`
+	Cursor *Cursor
+}
+
+func ParsePaginatedCursorQuery(r *http.Request) (*PaginatedCursorQuery, error) {
+	query := r.URL.Query()
+	limit, err := getDefaultQueryIntParam(&query, "limit", 20)
+	if err != nil {
+		return nil, err
+	}
+	paginatedQuery := &PaginatedCursorQuery{Limit: limit}
+	if cursorParam := query.Get("cursor"); cursorParam != "" {
+		if paginatedQuery.Cursor, err = DecodeCursor(cursorParam); err != nil {
+			return nil, err
+		}
+	}
+	return paginatedQuery, nil
+}
+
+const (
+	CommentSortOldest = "oldest"
+	CommentSortNewest = "newest"
+	CommentSortTop    = "top"
+)
+
+// PaginatedCommentQuery is a page of comments sorted oldest first, newest first, or by the number of replies.
+type PaginatedCommentQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Sort   string `json:"sort" validate:"omitempty,oneof=oldest newest top"`
+	Cursor *Cursor
+}
+
+func ParsePaginatedCommentQuery(r *http.Request) (*PaginatedCommentQuery, error) {
+	paginatedQuery, err := ParsePaginatedCursorQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	return &PaginatedCommentQuery{
+		Limit:  paginatedQuery.Limit,
+		Sort:   r.URL.Query().Get("sort"),
+		Cursor: paginatedQuery.Cursor,
+	}, nil
+}
+
+// cursorArgs returns the query arguments of the cursor, nil ones when the first page is requested.
+func (q *PaginatedCursorQuery) cursorArgs() (any, any) {
+	return q.Cursor.args()
+}
+
+// cursorArgs returns the query arguments of the cursor, nil ones when the cursor is not used.
+func (q *PaginatedFeedQuery) cursorArgs() (any, any) {
+	return q.Cursor.args()
+}
+
+func (c *Cursor) args() (any, any) {
+	if c == nil {
+		return nil, nil
+	}
+	return c.CreatedAt, c.ID
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := &Cursor{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC), ID: 42}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Fatalf("expected %+v, got %+v", cursor, decoded)
+	}
+}
+
+func TestCursorWithScoreRoundTrip(t *testing.T) {
+	cursor := &Cursor{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), ID: 42, Score: 7}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID || decoded.Score != cursor.Score {
+		t.Fatalf("expected %+v, got %+v", cursor, decoded)
+	}
+}
+
+func TestParsePaginatedFeedQueryCursor(t *testing.T) {
+	cursor := &Cursor{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), ID: 42}
+
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr error
+		withCursor  bool
+	}{
+		{name: "offset mode", url: "/feed?offset=20"},
+		{name: "cursor mode", url: "/feed?cursor=" + cursor.Encode(), withCursor: true},
+		{name: "cursor with offset", url: "/feed?offset=20&cursor=" + cursor.Encode(), expectedErr: errCursorWithOffset},
+		{name: "malformed cursor", url: "/feed?cursor=bm90LWEtY3Vyc29y", expectedErr: ErrInvalidCursor},
+		{name: "ranked mode", url: "/feed?mode=ranked&offset=20"},
+		{name: "cursor with ranked mode", url: "/feed?mode=ranked&cursor=" + cursor.Encode(), expectedErr: errCursorWithRankedMode},
+		{name: "sort with ranked mode", url: "/feed?mode=ranked&sort_by=comments", expectedErr: errSortWithRankedMode},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := ParsePaginatedFeedQuery(httptest.NewRequest("GET", tc.url, nil))
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if (query.Cursor != nil) != tc.withCursor {
+				t.Fatalf("expected cursor %v, got %+v", tc.withCursor, query.Cursor)
+			}
+		})
+	}
+}
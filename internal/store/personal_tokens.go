@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	ScopePostsWrite    = "posts:write"
+	ScopeCommentsWrite = "comments:write"
+	ScopeFeedRead      = "feed:read"
+)
+
+// PersonalToken is a long-lived API token a user creates for bots and integrations.
+// It grants only its scopes and never more than its owner has.
+type PersonalToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (t *PersonalToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+type PersonalTokenStore struct {
+	db *sql.DB
+}
+
+func (s *PersonalTokenStore) Create(ctx context.Context, token *PersonalToken) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO personal_tokens (user_id, name, token_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at`
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.Scopes,
+		token.ExpiresAt).Scan(
+		&token.ID,
+		&token.CreatedAt)
+}
+
+// Use returns the unexpired token with the given hash and records that it was used.
+func (s *PersonalTokenStore) Use(ctx context.Context, tokenHash string) (*PersonalToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+UPDATE personal_tokens SET last_used_at = NOW()
+WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING id, user_id, name, scopes, expires_at, last_used_at, created_at`
+	token := &PersonalToken{TokenHash: tokenHash}
+	m := pgtype.NewMap()
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		m.SQLScanner(&token.Scopes),
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+func (s *PersonalTokenStore) GetByUserID(ctx context.Context, userID int64) ([]*PersonalToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at FROM personal_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := pgtype.NewMap()
+	tokens := []*PersonalToken{}
+	for rows.Next() {
+		token := &PersonalToken{}
+		err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			m.SQLScanner(&token.Scopes),
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// Delete revokes a token of the user, returning ErrNotFound if the user has no such token.
+func (s *PersonalTokenStore) Delete(ctx context.Context, id, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM personal_tokens WHERE id = $1 AND user_id = $2`
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
@@ -15,15 +16,20 @@ type PostStore struct {
 }
 
 type Post struct {
-	ID        int64      `json:"id"`
-	Content   string     `json:"content"`
-	Title     string     `json:"title"`
-	UserID    int64      `json:"user_id"`
-	Tags      []string   `json:"tags"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	Version   int64      `json:"version"`
-	Comments  []*Comment `json:"comments"`
+	ID        int64     `json:"id"`
+	Content   string    `json:"content"`
+	Title     string    `json:"title"`
+	UserID    int64     `json:"user_id"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int64     `json:"version"`
+	// Comments is the first page of the top-level comments, loaded only with a single post
+	Comments           []*Comment `json:"comments"`
+	CommentsCount      int        `json:"comments_count"`
+	CommentsNextCursor string     `json:"comments_next_cursor,omitempty"`
+	// ReactionSummary is loaded only with a single post
+	*ReactionSummary
 }
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
@@ -103,6 +109,42 @@ SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at, p.updated_at,
 	return post, nil
 }
 
+// GetByUserID returns the posts of the user, newest first.
+func (s *PostStore) GetByUserID(ctx context.Context, userID int64) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+
+	query := `
+SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at, p.updated_at, p.version FROM posts p
+WHERE p.user_id = $1
+ORDER BY p.created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	posts := []*Post{}
+	m := pgtype.NewMap()
+	for rows.Next() {
+		post := &Post{}
+		err := rows.Scan(
+			&post.ID,
+			&post.Content,
+			&post.Title,
+			&post.UserID,
+			m.SQLScanner(&post.Tags),
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
 func (s *PostStore) DeleteByID(ctx context.Context, id int64) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
@@ -126,60 +168,339 @@ DELETE FROM posts WHERE id = $1`
 }
 
 type PostWithMetadata struct {
-	ID            int64     `json:"id"`
-	Content       string    `json:"content"`
-	Title         string    `json:"title"`
-	UserID        int       `json:"userId"`
-	Tags          []string  `json:"tags"`
-	CreatedAt     time.Time `json:"createdAt"`
-	CommentsCount int       `json:"commentsCount"`
+	ID             int64       `json:"id"`
+	Content        string      `json:"content"`
+	Title          string      `json:"title"`
+	UserID         int         `json:"userId"`
+	Tags           []string    `json:"tags"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	CommentsCount  int         `json:"commentsCount"`
+	LastActivityAt time.Time   `json:"lastActivityAt"`
+	Author         *PostAuthor `json:"author"`
+	ReactionSummary
+}
+
+// PostAuthor is the public profile of the author of a post in the feed.
+type PostAuthor struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// feedSortColumns maps the sort keys of the feed to the columns of the feed query.
+// Only these columns and sortDirections ever reach the SQL text.
+var feedSortColumns = map[string]string{
+	"":                "created_at",
+	FeedSortCreatedAt: "created_at",
+	FeedSortComments:  "comments_count",
+	FeedSortActivity:  "last_activity_at",
+}
+
+var sortDirections = map[string]string{
+	"asc":  "ASC",
+	"desc": "DESC",
+}
+
+// feedOrder returns the column and direction the feed is sorted by. The feed is sorted by creation time
+// ascending by default, while the most commented and most recently active posts come first unless
+// asked otherwise.
+func feedOrder(paginatedQuery *PaginatedFeedQuery) (string, string) {
+	column := feedSortColumns[paginatedQuery.SortBy]
+	direction, ok := sortDirections[paginatedQuery.Sort]
+	if !ok {
+		direction = "ASC"
+		if column != "created_at" {
+			direction = "DESC"
+		}
+	}
+	return column, direction
+}
+
+// FeedTimeline is a window of the precomputed timeline of a user. Posts are pushed to the timelines of the
+// followers of their author when they are created, except for the authors with too many followers, which
+// are read from the follows like the posts older than the oldest post of the timeline.
+type FeedTimeline struct {
+	// PostIDs are the newest posts of the timeline after the cursor of the page.
+	PostIDs []int64
+	// Last is the position of the last of PostIDs when the timeline has more posts, nil when PostIDs
+	// reach its oldest post.
+	Last         *Cursor
+	Oldest       time.Time
+	CelebrityIDs []int64
+}
+
+// TimelineEligible tells whether the page can be read from the timeline of the user, which holds the newest
+// posts by creation time. Offset and the filters that leave out the newest posts would read past the window.
+func (q *PaginatedFeedQuery) TimelineEligible() bool {
+	column, direction := feedOrder(q)
+	return column == "created_at" && direction == "DESC" && q.Offset == 0 &&
+		q.Search == "" && len(q.Tags) == 0 && q.Until.IsZero()
+}
+
+// GetUserFeed returns a page of the posts of the users the user follows, and of the user when asked to,
+// leaving out pending follow requests and the users the user has muted or is in a block with, and the
+// cursor of the next page, nil on the last one or when sorting by comments.
+func (s *PostStore) GetUserFeed(ctx context.Context, user *User, paginatedQuery *PaginatedFeedQuery) ([]*PostWithMetadata, *Cursor, error) {
+	timeline := paginatedQuery.Timeline
+	if timeline == nil || !paginatedQuery.TimelineEligible() ||
+		(paginatedQuery.Cursor != nil && !paginatedQuery.Cursor.CreatedAt.After(timeline.Oldest)) {
+		return s.getUserFeed(ctx, user, paginatedQuery, nil)
+	}
+	feed, next, err := s.getUserFeed(ctx, user, paginatedQuery, timeline)
+	if err != nil {
+		return nil, nil, err
+	}
+	if next != nil {
+		return feed, next, nil
+	}
+	if timeline.Last != nil {
+		// muted posts of the window left the page short, the next one starts after the window
+		return feed, timeline.Last, nil
+	}
+	// the posts older than the timeline are read from the follows
+	olderThanTimeline := &Cursor{CreatedAt: timeline.Oldest, ID: math.MaxInt64}
+	if len(feed) == paginatedQuery.Limit {
+		return feed, olderThanTimeline, nil
+	}
+	older := *paginatedQuery
+	older.Limit -= len(feed)
+	older.Cursor = olderThanTimeline
+	older.Timeline = nil
+	olderFeed, next, err := s.getUserFeed(ctx, user, &older, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(feed, olderFeed...), next, nil
 }
 
-func (s *PostStore) GetUserFeed(ctx context.Context, user *User, paginatedQuery *PaginatedFeedQuery) ([]*PostWithMetadata, error) {
+// getUserFeed reads a page of the feed from the follows of the user, or from the window of the timeline
+// and the posts of the celebrities within it.
+func (s *PostStore) getUserFeed(ctx context.Context, user *User, paginatedQuery *PaginatedFeedQuery, timeline *FeedTimeline) ([]*PostWithMetadata, *Cursor, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
+	column, direction := feedOrder(paginatedQuery)
+	// the cursor is the last post of the previous page in the order of the page and applies only to
+	// the time columns
+	keyset := column != "comments_count"
+	args := []any{
+		user.ID,
+		paginatedQuery.Search,
+		paginatedQuery.Tags,
+		// one extra row tells whether there is a next page
+		paginatedQuery.Limit + 1,
+		paginatedQuery.Offset,
+		nullTime(paginatedQuery.Since),
+		nullTime(paginatedQuery.Until),
+		paginatedQuery.IncludeOwn,
+	}
+	cursorCondition := ""
+	if keyset {
+		cursorOperator := ">"
+		if direction == "DESC" {
+			cursorOperator = "<"
+		}
+		cursorCondition = `WHERE ($9::timestamptz IS NULL OR (feed.` + column + `, feed.id) ` + cursorOperator + ` ($9, $10))`
+		cursorCreatedAt, cursorID := paginatedQuery.cursorArgs()
+		args = append(args, cursorCreatedAt, cursorID)
+	}
+	followed := `EXISTS (
+            SELECT 1 FROM user_follower uf
+            WHERE uf.user_id = p.user_id AND uf.follower_id = $1 AND uf.status = 'accepted'
+        )`
+	window := ""
+	if timeline != nil {
+		// the follows are checked only for the posts of the celebrities
+		n := len(args)
+		followed = fmt.Sprintf(`p.id = ANY($%d) OR (p.user_id = ANY($%d) AND %s)`, n+1, n+2, followed)
+		window = fmt.Sprintf(`
+    AND (p.created_at, p.id) >= ($%d::timestamptz, $%d::bigint)`, n+3, n+4)
+		low, lowID := timeline.Oldest, int64(math.MaxInt64)
+		if timeline.Last != nil {
+			low, lowID = timeline.Last.CreatedAt, timeline.Last.ID
+		}
+		args = append(args, timeline.PostIDs, timeline.CelebrityIDs, low, lowID)
+	}
 	query := `
-SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at, count(c.id) as comments_count
+SELECT id, content, title, user_id, tags, created_at, comments_count, last_activity_at,
+       username, display_name, avatar_url, reactions, my_reaction
+FROM (
+    SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at, count(c.id) AS comments_count,
+           GREATEST(p.created_at, max(c.created_at)) AS last_activity_at,
+           u.username, u.display_name, u.avatar_url,
+           ` + reactionCountsColumn + ` AS reactions, ` + myReactionColumn(1) + ` AS my_reaction
+    FROM posts p
+    JOIN users u ON u.id = p.user_id
+    LEFT JOIN comments c ON p.id = c.post_id AND c.deleted_at IS NULL
+    WHERE (
+        ` + followed + `
+        OR ($8 AND p.user_id = $1)
+    )
+    AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id)
+    AND NOT EXISTS (
+        SELECT 1 FROM user_blocks b
+        WHERE (b.user_id = $1 AND b.blocked_id = p.user_id) OR (b.user_id = p.user_id AND b.blocked_id = $1)
+    )
+    AND (p.title ILIKE '%' || $2 || '%' OR p.content ILIKE '%' || $2 || '%')
+    AND (p.tags @> $3 OR $3 IS NULL)
+    AND ($6::timestamptz IS NULL OR p.created_at >= $6)
+    AND ($7::timestamptz IS NULL OR p.created_at < $7)` + window + `
+    GROUP BY p.id, u.id
+) feed
+` + cursorCondition + `
+ORDER BY feed.` + column + ` ` + direction + `, feed.id ` + direction + `
+LIMIT $4 OFFSET $5`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	userFeed := make([]*PostWithMetadata, 0, paginatedQuery.Limit)
+	m := pgtype.NewMap()
+	for rows.Next() {
+		post := &PostWithMetadata{Author: &PostAuthor{}}
+
+		err = rows.Scan(
+			&post.ID,
+			&post.Content,
+			&post.Title,
+			&post.UserID,
+			m.SQLScanner(&post.Tags),
+			&post.CreatedAt,
+			&post.CommentsCount,
+			&post.LastActivityAt,
+			&post.Author.Username,
+			&post.Author.DisplayName,
+			&post.Author.AvatarURL,
+			(*reactionCounts)(&post.Reactions),
+			&post.MyReaction)
+		if err != nil {
+			return nil, nil, err
+		}
+		post.Author.ID = int64(post.UserID)
+		userFeed = append(userFeed, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(userFeed) <= paginatedQuery.Limit {
+		return userFeed, nil, nil
+	}
+	userFeed = userFeed[:paginatedQuery.Limit]
+	if !keyset {
+		return userFeed, nil, nil
+	}
+	last := userFeed[len(userFeed)-1]
+	next := &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	if column == "last_activity_at" {
+		next.CreatedAt = last.LastActivityAt
+	}
+	return userFeed, next, nil
+}
+
+// FeedCandidate is a post considered for the ranked feed with the signals it is ranked by.
+type FeedCandidate struct {
+	*PostWithMetadata
+	// Affinity is the number of comments of the viewer on the posts of the author
+	Affinity int
+	// SecondDegree tells that the viewer does not follow the author but a user the viewer follows does
+	SecondDegree bool
+}
+
+// GetFeedCandidates returns up to limit of the newest posts of the users the user follows, of the public
+// users they follow and of the user when asked to, with the same filters as the feed except pagination.
+func (s *PostStore) GetFeedCandidates(ctx context.Context, user *User, paginatedQuery *PaginatedFeedQuery, limit int) ([]*FeedCandidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+WITH followed AS (
+    SELECT user_id FROM user_follower WHERE follower_id = $1 AND status = 'accepted'
+), second_degree AS (
+    SELECT DISTINCT uf.user_id FROM user_follower uf
+    JOIN followed f ON f.user_id = uf.follower_id
+    WHERE uf.status = 'accepted' AND uf.user_id <> $1 AND uf.user_id NOT IN (SELECT user_id FROM followed)
+), affinity AS (
+    SELECT ap.user_id, count(*) AS interactions FROM comments c
+    JOIN posts ap ON ap.id = c.post_id
+    WHERE c.user_id = $1
+    GROUP BY ap.user_id
+)
+SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at,
+       cs.comments_count, GREATEST(p.created_at, cs.last_comment_at),
+       u.username, u.display_name, u.avatar_url,
+       ` + reactionCountsColumn + `, ` + myReactionColumn(1) + `,
+       COALESCE(a.interactions, 0), sd.user_id IS NOT NULL
 FROM posts p
-LEFT JOIN comments c ON p.id = c.post_id
-LEFT JOIN user_follower uf ON p.user_id = uf.follower_id
-WHERE uf.user_id = $1
+JOIN users u ON u.id = p.user_id
+CROSS JOIN LATERAL (
+    SELECT count(*) AS comments_count, max(c.created_at) AS last_comment_at FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL
+) cs
+LEFT JOIN second_degree sd ON sd.user_id = p.user_id AND NOT u.is_private
+LEFT JOIN affinity a ON a.user_id = p.user_id
+WHERE (
+    p.user_id IN (SELECT user_id FROM followed)
+    OR sd.user_id IS NOT NULL
+    OR ($6 AND p.user_id = $1)
+)
+AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.user_id = $1 AND m.muted_id = p.user_id)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.user_id = $1 AND b.blocked_id = p.user_id) OR (b.user_id = p.user_id AND b.blocked_id = $1)
+)
 AND (p.title ILIKE '%' || $2 || '%' OR p.content ILIKE '%' || $2 || '%')
 AND (p.tags @> $3 OR $3 IS NULL)
-GROUP BY p.id, p.created_at
-ORDER BY p.created_at ` + paginatedQuery.Sort +
-		` LIMIT $4 OFFSET $5`
-	log.Print(query)
-	log.Print(paginatedQuery)
+AND ($4::timestamptz IS NULL OR p.created_at >= $4)
+AND ($5::timestamptz IS NULL OR p.created_at < $5)
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT $7`
 	rows, err := s.db.QueryContext(
 		ctx,
 		query,
 		user.ID,
 		paginatedQuery.Search,
 		paginatedQuery.Tags,
-		paginatedQuery.Limit,
-		paginatedQuery.Offset)
+		nullTime(paginatedQuery.Since),
+		nullTime(paginatedQuery.Until),
+		paginatedQuery.IncludeOwn,
+		limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	userFeed := make([]*PostWithMetadata, 0, paginatedQuery.Limit)
+	candidates := []*FeedCandidate{}
 	m := pgtype.NewMap()
 	for rows.Next() {
-		post := &PostWithMetadata{}
-
-		err = rows.Scan(
+		post := &PostWithMetadata{Author: &PostAuthor{}}
+		candidate := &FeedCandidate{PostWithMetadata: post}
+		err := rows.Scan(
 			&post.ID,
 			&post.Content,
 			&post.Title,
 			&post.UserID,
 			m.SQLScanner(&post.Tags),
 			&post.CreatedAt,
-			&post.CommentsCount)
+			&post.CommentsCount,
+			&post.LastActivityAt,
+			&post.Author.Username,
+			&post.Author.DisplayName,
+			&post.Author.AvatarURL,
+			(*reactionCounts)(&post.Reactions),
+			&post.MyReaction,
+			&candidate.Affinity,
+			&candidate.SecondDegree)
 		if err != nil {
 			return nil, err
 		}
-		userFeed = append(userFeed, post)
+		post.Author.ID = int64(post.UserID)
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// nullTime returns nil for the zero time so that optional time filters are skipped by the query.
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
 	}
-	return userFeed, nil
+	return t
 }
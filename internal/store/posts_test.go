@@ -0,0 +1,297 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetUserFeed(t *testing.T) {
+	db := newTestDB(t)
+	s := &PostStore{db: db}
+	ctx := context.Background()
+
+	viewer := createTestUser(t, db, "viewer")
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	stranger := createTestUser(t, db, "stranger")
+	muted := createTestUser(t, db, "muted")
+	for _, followed := range []int64{alice, bob, muted} {
+		if _, err := db.Exec(`INSERT INTO user_follower (user_id, follower_id) VALUES ($1, $2)`, followed, viewer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO user_mutes (user_id, muted_id) VALUES ($1, $2)`, viewer, muted); err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	createPost := func(userID int64, createdAt time.Time, commentedAt ...time.Time) int64 {
+		t.Helper()
+		var id int64
+		err := db.QueryRow(`
+INSERT INTO posts (title, content, user_id, created_at) VALUES ('title', 'content', $1, $2)
+RETURNING id`, userID, createdAt).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, at := range commentedAt {
+			_, err := db.Exec(`INSERT INTO comments (post_id, user_id, content, created_at) VALUES ($1, $2, 'comment', $3)`, id, viewer, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return id
+	}
+	// activity: p2 at t0+11h, p3 at t0+5h, p1 at t0+1h
+	p1 := createPost(alice, t0.Add(time.Hour))
+	p2 := createPost(bob, t0.Add(2*time.Hour), t0.Add(10*time.Hour), t0.Add(11*time.Hour))
+	p3 := createPost(alice, t0.Add(3*time.Hour), t0.Add(5*time.Hour))
+	createPost(stranger, t0.Add(4*time.Hour))
+	mutedPost := createPost(muted, t0.Add(4*time.Hour))
+	own := createPost(viewer, t0.Add(6*time.Hour))
+
+	tests := []struct {
+		name     string
+		query    PaginatedFeedQuery
+		expected []int64
+	}{
+		{name: "default order", query: PaginatedFeedQuery{}, expected: []int64{p1, p2, p3}},
+		{name: "created at asc", query: PaginatedFeedQuery{Sort: "asc", SortBy: FeedSortCreatedAt}, expected: []int64{p1, p2, p3}},
+		{name: "created at desc", query: PaginatedFeedQuery{Sort: "desc", SortBy: FeedSortCreatedAt}, expected: []int64{p3, p2, p1}},
+		{name: "most commented", query: PaginatedFeedQuery{SortBy: FeedSortComments}, expected: []int64{p2, p3, p1}},
+		{name: "least commented", query: PaginatedFeedQuery{Sort: "asc", SortBy: FeedSortComments}, expected: []int64{p1, p3, p2}},
+		{name: "most recent activity", query: PaginatedFeedQuery{SortBy: FeedSortActivity}, expected: []int64{p2, p3, p1}},
+		{name: "least recent activity", query: PaginatedFeedQuery{Sort: "asc", SortBy: FeedSortActivity}, expected: []int64{p1, p3, p2}},
+		{name: "since", query: PaginatedFeedQuery{Since: t0.Add(2 * time.Hour)}, expected: []int64{p2, p3}},
+		{name: "until", query: PaginatedFeedQuery{Until: t0.Add(3 * time.Hour)}, expected: []int64{p1, p2}},
+		{name: "since and until", query: PaginatedFeedQuery{Since: t0.Add(2 * time.Hour), Until: t0.Add(3 * time.Hour)}, expected: []int64{p2}},
+		{name: "window sorted by comments", query: PaginatedFeedQuery{SortBy: FeedSortComments, Since: t0.Add(2 * time.Hour)}, expected: []int64{p2, p3}},
+		{name: "window sorted by activity", query: PaginatedFeedQuery{SortBy: FeedSortActivity, Until: t0.Add(3 * time.Hour)}, expected: []int64{p2, p1}},
+		{name: "offset", query: PaginatedFeedQuery{Sort: "desc", Offset: 1}, expected: []int64{p2, p1}},
+		{name: "own posts", query: PaginatedFeedQuery{Sort: "desc", IncludeOwn: true}, expected: []int64{own, p3, p2, p1}},
+		{
+			name:     "timeline",
+			query:    PaginatedFeedQuery{Sort: "desc", Timeline: &FeedTimeline{PostIDs: []int64{p3, mutedPost}, Oldest: t0.Add(time.Hour)}},
+			expected: []int64{p3, p1},
+		},
+		{
+			name: "timeline with celebrities",
+			query: PaginatedFeedQuery{Sort: "desc", Timeline: &FeedTimeline{
+				PostIDs: []int64{p3}, Oldest: t0.Add(time.Hour), CelebrityIDs: []int64{bob},
+			}},
+			expected: []int64{p3, p2, p1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			query := tc.query
+			query.Limit = 10
+			feed, next, err := s.GetUserFeed(ctx, &User{ID: viewer}, &query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFeedIDs(t, tc.expected, feed)
+			if next != nil {
+				t.Fatalf("expected the last page, got cursor %+v", next)
+			}
+		})
+	}
+
+	pages := []struct {
+		name  string
+		query PaginatedFeedQuery
+		pages [][]int64
+	}{
+		{name: "created at desc", query: PaginatedFeedQuery{Sort: "desc"}, pages: [][]int64{{p3, p2}, {p1}}},
+		{name: "created at asc", query: PaginatedFeedQuery{Sort: "asc"}, pages: [][]int64{{p1, p2}, {p3}}},
+		{name: "most recent activity", query: PaginatedFeedQuery{SortBy: FeedSortActivity}, pages: [][]int64{{p2, p3}, {p1}}},
+		{name: "least recent activity", query: PaginatedFeedQuery{Sort: "asc", SortBy: FeedSortActivity}, pages: [][]int64{{p1, p3}, {p2}}},
+		{name: "cursor within a window", query: PaginatedFeedQuery{Sort: "desc", Since: t0.Add(2 * time.Hour)}, pages: [][]int64{{p3, p2}}},
+	}
+	for _, tc := range pages {
+		t.Run("cursor "+tc.name, func(t *testing.T) {
+			query := tc.query
+			query.Limit = 2
+			for i, expected := range tc.pages {
+				feed, next, err := s.GetUserFeed(ctx, &User{ID: viewer}, &query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertFeedIDs(t, expected, feed)
+				if last := i == len(tc.pages)-1; last != (next == nil) {
+					t.Fatalf("page %d: unexpected cursor %+v", i, next)
+				}
+				query.Cursor = next
+			}
+		})
+	}
+
+	timelinePages := []struct {
+		name     string
+		query    PaginatedFeedQuery
+		expected []int64
+		next     *Cursor
+	}{
+		{
+			name: "timeline window with a next page",
+			query: PaginatedFeedQuery{Sort: "desc", Limit: 1, Timeline: &FeedTimeline{
+				PostIDs: []int64{p3, p2}, Last: &Cursor{CreatedAt: t0.Add(2 * time.Hour), ID: p2}, Oldest: t0.Add(time.Hour),
+			}},
+			expected: []int64{p3},
+			next:     &Cursor{CreatedAt: t0.Add(3 * time.Hour), ID: p3},
+		},
+		{
+			name: "timeline window short of muted posts",
+			query: PaginatedFeedQuery{Sort: "desc", Limit: 3, Timeline: &FeedTimeline{
+				PostIDs: []int64{mutedPost, p3, p2}, Last: &Cursor{CreatedAt: t0.Add(2 * time.Hour), ID: p2}, Oldest: t0.Add(time.Hour),
+			}},
+			expected: []int64{p3, p2},
+			next:     &Cursor{CreatedAt: t0.Add(2 * time.Hour), ID: p2},
+		},
+		{
+			name: "timeline window followed by the follows",
+			query: PaginatedFeedQuery{Sort: "desc", Limit: 2, Timeline: &FeedTimeline{
+				PostIDs: []int64{p3}, Oldest: t0.Add(3 * time.Hour), CelebrityIDs: []int64{bob},
+			}},
+			expected: []int64{p3, p2},
+			next:     &Cursor{CreatedAt: t0.Add(2 * time.Hour), ID: p2},
+		},
+		{
+			name: "cursor older than the timeline",
+			query: PaginatedFeedQuery{Sort: "desc", Limit: 2, Cursor: &Cursor{CreatedAt: t0.Add(2 * time.Hour), ID: p2}, Timeline: &FeedTimeline{
+				Oldest: t0.Add(3 * time.Hour),
+			}},
+			expected: []int64{p1},
+		},
+	}
+	for _, tc := range timelinePages {
+		t.Run(tc.name, func(t *testing.T) {
+			query := tc.query
+			feed, next, err := s.GetUserFeed(ctx, &User{ID: viewer}, &query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFeedIDs(t, tc.expected, feed)
+			if (next == nil) != (tc.next == nil) || (next != nil && (next.ID != tc.next.ID || !next.CreatedAt.Equal(tc.next.CreatedAt))) {
+				t.Fatalf("expected cursor %+v, got %+v", tc.next, next)
+			}
+		})
+	}
+
+	t.Run("no cursor when sorting by comments", func(t *testing.T) {
+		query := PaginatedFeedQuery{SortBy: FeedSortComments, Limit: 1}
+		feed, next, err := s.GetUserFeed(ctx, &User{ID: viewer}, &query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertFeedIDs(t, []int64{p2}, feed)
+		if next != nil {
+			t.Fatalf("expected no cursor, got %+v", next)
+		}
+	})
+
+	t.Run("author", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE users SET display_name = 'Alice', avatar_url = 'https://example.com/alice.png' WHERE id = $1`, alice); err != nil {
+			t.Fatal(err)
+		}
+		query := PaginatedFeedQuery{Sort: "asc", Limit: 1}
+		feed, _, err := s.GetUserFeed(ctx, &User{ID: viewer}, &query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := PostAuthor{ID: alice, Username: "alice", DisplayName: "Alice", AvatarURL: "https://example.com/alice.png"}
+		if *feed[0].Author != expected {
+			t.Fatalf("expected author %+v, got %+v", expected, *feed[0].Author)
+		}
+	})
+
+	t.Run("comment count and activity", func(t *testing.T) {
+		query := PaginatedFeedQuery{Sort: "asc", Limit: 10}
+		feed, _, err := s.GetUserFeed(ctx, &User{ID: viewer}, &query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if feed[1].CommentsCount != 2 {
+			t.Fatalf("expected 2 comments, got %d", feed[1].CommentsCount)
+		}
+		if !feed[1].LastActivityAt.Equal(t0.Add(11 * time.Hour)) {
+			t.Fatalf("expected activity at %v, got %v", t0.Add(11*time.Hour), feed[1].LastActivityAt)
+		}
+		if !feed[0].LastActivityAt.Equal(feed[0].CreatedAt) {
+			t.Fatalf("expected activity of a post without comments at its creation, got %v", feed[0].LastActivityAt)
+		}
+	})
+}
+
+func TestGetFeedCandidates(t *testing.T) {
+	db := newTestDB(t)
+	s := &PostStore{db: db}
+	ctx := context.Background()
+
+	viewer := createTestUser(t, db, "viewer")
+	alice := createTestUser(t, db, "alice")
+	carol := createTestUser(t, db, "carol")
+	dave := createTestUser(t, db, "dave")
+	follows := [][2]int64{{alice, viewer}, {carol, alice}, {dave, alice}}
+	for _, follow := range follows {
+		if _, err := db.Exec(`INSERT INTO user_follower (user_id, follower_id) VALUES ($1, $2)`, follow[0], follow[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE users SET is_private = TRUE WHERE id = $1`, dave); err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	createPost := func(userID int64, createdAt time.Time) int64 {
+		t.Helper()
+		var id int64
+		err := db.QueryRow(`
+INSERT INTO posts (title, content, user_id, created_at) VALUES ('title', 'content', $1, $2)
+RETURNING id`, userID, createdAt).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	alicePost := createPost(alice, t0.Add(time.Hour))
+	carolPost := createPost(carol, t0.Add(2*time.Hour))
+	createPost(dave, t0.Add(3*time.Hour))
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec(`INSERT INTO comments (post_id, user_id, content) VALUES ($1, $2, 'comment')`, alicePost, viewer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	candidates, err := s.GetFeedCandidates(ctx, &User{ID: viewer}, &PaginatedFeedQuery{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected the posts of alice and carol, got %d posts", len(candidates))
+	}
+	carolCandidate, aliceCandidate := candidates[0], candidates[1]
+	if carolCandidate.ID != carolPost || !carolCandidate.SecondDegree || carolCandidate.Affinity != 0 {
+		t.Fatalf("expected the second degree post of carol, got %+v", carolCandidate)
+	}
+	if aliceCandidate.ID != alicePost || aliceCandidate.SecondDegree || aliceCandidate.Affinity != 2 || aliceCandidate.CommentsCount != 2 {
+		t.Fatalf("expected the post of alice with 2 comments of the viewer, got %+v", aliceCandidate)
+	}
+}
+
+func assertFeedIDs(t *testing.T, expected []int64, feed []*PostWithMetadata) {
+	t.Helper()
+	ids := make([]int64, 0, len(feed))
+	for _, post := range feed {
+		ids = append(ids, post.ID)
+	}
+	if len(ids) != len(expected) {
+		t.Fatalf("expected posts %v, got %v", expected, ids)
+	}
+	for i := range ids {
+		if ids[i] != expected[i] {
+			t.Fatalf("expected posts %v, got %v", expected, ids)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	ReactionLike  = "like"
+	ReactionLove  = "love"
+	ReactionHaha  = "haha"
+	ReactionWow   = "wow"
+	ReactionSad   = "sad"
+	ReactionAngry = "angry"
+)
+
+// ReactionStore keeps the reactions of users to posts, one per user and post.
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// ReactionSummary is the number of each reaction to a post and the reaction of the viewer, nil if none.
+type ReactionSummary struct {
+	Reactions  map[string]int `json:"reactions"`
+	MyReaction *string        `json:"my_reaction"`
+}
+
+// Total returns the number of all the reactions to the post.
+func (s ReactionSummary) Total() int {
+	total := 0
+	for _, count := range s.Reactions {
+		total += count
+	}
+	return total
+}
+
+// reactionCounts scans the counts of the reactions to a post aggregated as a JSON object.
+type reactionCounts map[string]int
+
+func (c *reactionCounts) Scan(src any) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	default:
+		return fmt.Errorf("cannot scan %T into reaction counts", src)
+	}
+}
+
+// reactionCountsColumn and myReactionColumn select the reactions of the post aliased p for the user
+// passed as the parameter of the given number.
+const reactionCountsColumn = `COALESCE((
+    SELECT jsonb_object_agg(rc.reaction, rc.count) FROM post_reaction_counts rc WHERE rc.post_id = p.id AND rc.count > 0
+), '{}')`
+
+func myReactionColumn(userParam int) string {
+	return fmt.Sprintf(`(SELECT r.reaction FROM post_reactions r WHERE r.post_id = p.id AND r.user_id = $%d)`, userParam)
+}
+
+// Set sets the reaction of the user to the post, replacing the previous one, returning ErrBlocked if the author
+// of the post and the user are in a block.
+func (s *ReactionStore) Set(ctx context.Context, postID, userID int64, reaction string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO post_reactions (post_id, user_id, reaction)
+SELECT p.id, $2, $3 FROM posts p
+WHERE p.id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.user_id = p.user_id AND b.blocked_id = $2) OR (b.user_id = $2 AND b.blocked_id = p.user_id)
+)
+ON CONFLICT (post_id, user_id) DO UPDATE SET reaction = EXCLUDED.reaction, created_at = NOW()`
+	res, err := s.db.ExecContext(ctx, query, postID, userID, reaction)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// Delete removes the reaction of the user to the post, returning ErrNotFound if the user has not reacted to it.
+func (s *ReactionStore) Delete(ctx context.Context, postID, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2`
+	res, err := s.db.ExecContext(ctx, query, postID, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetSummary returns the counts of the reactions to the post and the reaction of the viewer.
+func (s *ReactionStore) GetSummary(ctx context.Context, postID, viewerID int64) (*ReactionSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT ` + reactionCountsColumn + `, ` + myReactionColumn(2) + `
+FROM posts p WHERE p.id = $1`
+	summary := &ReactionSummary{}
+	var myReaction sql.NullString
+	err := s.db.QueryRowContext(ctx, query, postID, viewerID).Scan((*reactionCounts)(&summary.Reactions), &myReaction)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if myReaction.Valid {
+		summary.MyReaction = &myReaction.String
+	}
+	return summary, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReactions(t *testing.T) {
+	db := newTestDB(t)
+	s := &ReactionStore{db: db}
+	ctx := context.Background()
+
+	author := createTestUser(t, db, "author")
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	var postID int64
+	if err := db.QueryRow(`INSERT INTO posts (title, content, user_id) VALUES ('title', 'content', $1) RETURNING id`, author).Scan(&postID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reaction := range []struct {
+		userID   int64
+		reaction string
+	}{{alice, ReactionLike}, {bob, ReactionLike}, {alice, ReactionLove}} {
+		if err := s.Set(ctx, postID, reaction.userID, reaction.reaction); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertSummary := func(viewerID int64, expected map[string]int, expectedMine string) {
+		t.Helper()
+		summary, err := s.GetSummary(ctx, postID, viewerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(summary.Reactions, expected) {
+			t.Fatalf("expected reactions %v, got %v", expected, summary.Reactions)
+		}
+		mine := ""
+		if summary.MyReaction != nil {
+			mine = *summary.MyReaction
+		}
+		if mine != expectedMine {
+			t.Fatalf("expected my reaction %q, got %q", expectedMine, mine)
+		}
+	}
+	// changing a reaction moves it between the counts
+	assertSummary(alice, map[string]int{ReactionLike: 1, ReactionLove: 1}, ReactionLove)
+	assertSummary(author, map[string]int{ReactionLike: 1, ReactionLove: 1}, "")
+
+	if err := s.Delete(ctx, postID, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, postID, bob); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	assertSummary(bob, map[string]int{ReactionLove: 1}, "")
+
+	if _, err := db.Exec(`INSERT INTO user_blocks (user_id, blocked_id) VALUES ($1, $2)`, author, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, postID, bob, ReactionLike); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected ErrBlocked, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token *RefreshToken) error {
+	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		return s.create(ctx, trx, token)
+	})
+}
+
+// TokenReusedError tells whose family of refresh tokens was revoked after one of its tokens was reused.
+// It matches ErrTokenReused.
+type TokenReusedError struct {
+	UserID   int64
+	FamilyID string
+}
+
+func (e *TokenReusedError) Error() string {
+	return ErrTokenReused.Error()
+}
+
+func (e *TokenReusedError) Unwrap() error {
+	return ErrTokenReused
+}
+
+// Rotate exchanges the refresh token identified by tokenHash for next, which
+// joins the same family. Presenting a token that was already rotated revokes
+// the whole family and returns a TokenReusedError.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, tokenHash string, next *RefreshToken) error {
+	var reused *TokenReusedError
+	err := withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		current, usedAt, revokedAt, err := s.getForUpdate(ctx, trx, tokenHash)
+		if err != nil {
+			return err
+		}
+		if revokedAt.Valid {
+			return ErrNotFound
+		}
+		if usedAt.Valid {
+			reused = &TokenReusedError{UserID: current.UserID, FamilyID: current.FamilyID}
+			return s.revokeFamily(ctx, trx, current.FamilyID)
+		}
+		if current.ExpiresAt.Before(time.Now()) {
+			return ErrNotFound
+		}
+		if err := s.markUsed(ctx, trx, current.ID); err != nil {
+			return err
+		}
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+		return s.create(ctx, trx, next)
+	})
+	if err != nil {
+		return err
+	}
+	if reused != nil {
+		return reused
+	}
+	return nil
+}
+
+func (s *RefreshTokenStore) getForUpdate(ctx context.Context, trx *sql.Tx, tokenHash string) (*RefreshToken, sql.NullTime, sql.NullTime, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT id, user_id, family_id, expires_at, used_at, revoked_at, created_at
+FROM refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE`
+	token := &RefreshToken{TokenHash: tokenHash}
+	var usedAt, revokedAt sql.NullTime
+	err := trx.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+		&token.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, usedAt, revokedAt, ErrNotFound
+		default:
+			return nil, usedAt, revokedAt, err
+		}
+	}
+	return token, usedAt, revokedAt, nil
+}
+
+func (s *RefreshTokenStore) markUsed(ctx context.Context, trx *sql.Tx, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`
+	_, err := trx.ExecContext(ctx, query, id)
+	return err
+}
+
+func (s *RefreshTokenStore) create(ctx context.Context, trx *sql.Tx, token *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+RETURNING id, created_at`
+	return trx.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt).Scan(
+		&token.ID,
+		&token.CreatedAt)
+}
+
+func (s *RefreshTokenStore) revokeFamily(ctx context.Context, trx *sql.Tx, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := trx.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// Revoke revokes the whole family the refresh token identified by tokenHash belongs to.
+func (s *RefreshTokenStore) Revoke(ctx context.Context, tokenHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+UPDATE refresh_tokens SET revoked_at = NOW()
+WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)
+  AND revoked_at IS NULL`
+	_, err := s.db.ExecContext(ctx, query, tokenHash)
+	return err
+}
+
+func (s *RefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TokenDenylist keeps track of access tokens that were revoked before they expired,
+// either one by one through their jti or all tokens of a user issued before a point in time.
+type TokenDenylist interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// Claim revokes the token like Revoke and reports whether this call revoked it, false if it was
+	// already revoked, so that single-use tokens are used at most once under concurrent requests.
+	Claim(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	RevokeUser(ctx context.Context, userID int64, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string, userID int64, issuedAt time.Time) (bool, error)
+}
+
+type RevokedTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+ON CONFLICT (jti) DO NOTHING`
+	_, err := s.db.ExecContext(ctx, query, jti, expiresAt)
+	return err
+}
+
+func (s *RevokedTokenStore) Claim(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+ON CONFLICT (jti) DO NOTHING`
+	res, err := s.db.ExecContext(ctx, query, jti, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RevokeUser revokes the tokens of the user issued before now. The time is kept to the microsecond, like
+// the issue time of tokens, so the tokens of a login right after a password change stay valid.
+func (s *RevokedTokenStore) RevokeUser(ctx context.Context, userID int64, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO user_token_revocation (user_id, revoked_at, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET revoked_at = EXCLUDED.revoked_at, expires_at = EXCLUDED.expires_at`
+	// Postgres rounds to the microsecond, which could move the revocation after a token issued right after it
+	revokedAt := time.Now().Truncate(time.Microsecond)
+	_, err := s.db.ExecContext(ctx, query, userID, revokedAt, expiresAt)
+	return err
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string, userID int64, issuedAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+    OR EXISTS (SELECT 1 FROM user_token_revocation WHERE user_id = $2 AND revoked_at > $3)`
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, query, jti, userID, issuedAt).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRevokeUser(t *testing.T) {
+	db := newTestDB(t)
+	s := &RevokedTokenStore{db: db}
+	ctx := context.Background()
+	userID := createTestUser(t, db, "alice")
+
+	if err := s.RevokeUser(ctx, userID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var revokedAt time.Time
+	if err := db.QueryRow(`SELECT revoked_at FROM user_token_revocation WHERE user_id = $1`, userID).Scan(&revokedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		expected bool
+	}{
+		{name: "token issued before the revocation", issuedAt: revokedAt.Add(-time.Second), expected: true},
+		// the iat of a token issued earlier in the same second is the second of the revocation
+		{name: "token issued before the revocation in the same second", issuedAt: revokedAt.Add(-time.Microsecond), expected: true},
+		// a login right after a password change
+		{name: "token issued right after the revocation", issuedAt: revokedAt.Add(time.Microsecond), expected: false},
+		{name: "token issued after the revocation", issuedAt: revokedAt.Add(time.Second), expected: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			revoked, err := s.IsRevoked(ctx, uuid.New().String(), userID, tc.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tc.expected {
+				t.Fatalf("expected revoked %v, got %v", tc.expected, revoked)
+			}
+		})
+	}
+}
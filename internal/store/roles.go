@@ -14,16 +14,17 @@ type Role struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
 	Level       int    `json:"level,omitempty"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
 }
 
 func (s *RoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
-	query := `SELECT id, name, description, level FROM roles WHERE name = $1`
+	query := `SELECT id, name, description, level, mfa_required FROM roles WHERE name = $1`
 	var role Role
 	err := s.db.QueryRowContext(
 		ctx, query, name).Scan(
-		&role.ID, &role.Name, &role.Description, &role.Level)
+		&role.ID, &role.Name, &role.Description, &role.Level, &role.MFARequired)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -32,3 +33,32 @@ func (s *RoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
 	}
 	return &role, nil
 }
+
+// RequireMFA makes two-factor authentication mandatory for roles with level at or above minLevel.
+// A minLevel of 0 removes the requirement from every role. The IDs of the users of the roles whose
+// requirement changed are returned.
+func (s *RoleStore) RequireMFA(ctx context.Context, minLevel int) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+WITH changed AS (
+    UPDATE roles SET mfa_required = ($1 > 0 AND level >= $1)
+    WHERE mfa_required <> ($1 > 0 AND level >= $1)
+    RETURNING id
+)
+SELECT u.id FROM users u JOIN changed c ON c.id = u.role_id`
+	rows, err := s.db.QueryContext(ctx, query, minLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	userIDs := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestRequireMFA(t *testing.T) {
+	db := newTestDB(t)
+	s := &RoleStore{db: db}
+	ctx := context.Background()
+	alice := createTestUser(t, db, "alice")
+
+	userIDs, err := s.RequireMFA(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(userIDs, alice) {
+		t.Fatalf("expected the users of the changed roles, got %v", userIDs)
+	}
+	// the requirement of no role changes
+	if userIDs, err = s.RequireMFA(ctx, 1); err != nil || len(userIDs) != 0 {
+		t.Fatalf("expected no users, got %v, %v", userIDs, err)
+	}
+	if userIDs, err = s.RequireMFA(ctx, 0); err != nil || !slices.Contains(userIDs, alice) {
+		t.Fatalf("expected the users of the changed roles, got %v, %v", userIDs, err)
+	}
+}
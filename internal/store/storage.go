@@ -8,8 +8,10 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("resource not found")
-	ErrConflict = errors.New("resource already exists")
+	ErrNotFound    = errors.New("resource not found")
+	ErrConflict    = errors.New("resource already exists")
+	ErrTokenReused = errors.New("refresh token has already been used")
+	ErrBlocked     = errors.New("user is blocked")
 
 	QueryTimoutDuration = time.Second * 5
 )
@@ -20,34 +22,114 @@ type Storage struct {
 		Update(context.Context, *Post) error
 		GetByID(context.Context, int64) (*Post, error)
 		DeleteByID(context.Context, int64) error
-		GetUserFeed(context.Context, *User, *PaginatedFeedQuery) ([]*PostWithMetadata, error)
+		GetUserFeed(context.Context, *User, *PaginatedFeedQuery) ([]*PostWithMetadata, *Cursor, error)
+		GetFeedCandidates(context.Context, *User, *PaginatedFeedQuery, int) ([]*FeedCandidate, error)
+		GetByUserID(context.Context, int64) ([]*Post, error)
 	}
 	Users interface {
-		Activate(context.Context, string) error
+		Activate(context.Context, string) (int64, error)
 		GetByID(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
+		ReplaceInvitation(context.Context, int64, string, time.Duration) error
+		CreatePasswordReset(context.Context, int64, string, time.Duration) error
+		ResetPassword(context.Context, string, *User) error
+		RequestEmailChange(ctx context.Context, userID int64, newEmail, inviteCode string, expirationTime time.Duration) error
+		Update(context.Context, *User) ([]int64, error)
+		Delete(ctx context.Context, userID int64, policy string) error
 	}
 	Comments interface {
-		GetByPostID(context.Context, int64) ([]*Comment, error)
+		GetReplies(ctx context.Context, postID int64, parentIDs []int64, viewerID int64) ([]*Comment, error)
+		GetByUserID(context.Context, int64) ([]*Comment, error)
+		GetByID(context.Context, int64) (*Comment, error)
+		GetPage(ctx context.Context, postID int64, parentID *int64, viewerID int64, query *PaginatedCommentQuery) ([]*Comment, *Cursor, error)
+		CountByPostID(ctx context.Context, postID, viewerID int64) (int, error)
 		Create(context.Context, *Comment) error
+		Update(ctx context.Context, comment *Comment, editorID int64) error
+		GetEdits(ctx context.Context, commentID int64) ([]*CommentEdit, error)
+		Delete(context.Context, int64) error
 	}
 	Followers interface {
-		Follow(ctx context.Context, user *User, follower *User) error
+		Follow(ctx context.Context, user *User, follower *User) (string, error)
 		Unfollow(ctx context.Context, user *User, follower *User) error
+		GetStats(ctx context.Context, userID, viewerID int64) (*FollowStats, error)
+		GetFollowers(ctx context.Context, userID, viewerID int64, query *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error)
+		GetFollowing(ctx context.Context, userID, viewerID int64, query *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error)
+		GetFollowRequests(ctx context.Context, userID int64, query *PaginatedCursorQuery) ([]*FollowUser, *Cursor, error)
+		AcceptFollowRequest(ctx context.Context, userID, followerID int64) error
+		RejectFollowRequest(ctx context.Context, userID, followerID int64) error
+		IsFollowing(ctx context.Context, userID, followerID int64) (bool, error)
+		GetFollowerIDs(ctx context.Context, userID int64) ([]int64, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
+		RequireMFA(context.Context, int) ([]int64, error)
+	}
+	MFA interface {
+		SetSecret(context.Context, int64, string) error
+		GetSecret(context.Context, int64) (string, error)
+		Enable(context.Context, int64, []string) error
+		UseRecoveryCode(context.Context, int64, string) error
+		UseTOTPStep(ctx context.Context, userID int64, step int64) error
+	}
+	RefreshTokens interface {
+		Create(context.Context, *RefreshToken) error
+		Rotate(context.Context, string, *RefreshToken) error
+		Revoke(context.Context, string) error
+		RevokeAllForUser(context.Context, int64) error
+	}
+	RevokedTokens TokenDenylist
+	Identities    interface {
+		GetUserID(ctx context.Context, provider, subject string) (int64, error)
+		Link(ctx context.Context, userID int64, provider, subject string) error
+		CreateUser(ctx context.Context, user *User, provider, subject string) error
+	}
+	LoginAttempts interface {
+		Get(ctx context.Context, scope, key string) (*LoginAttempt, error)
+		RecordFailure(ctx context.Context, scope, key string, window time.Duration) (*LoginAttempt, error)
+		Lock(ctx context.Context, scope, key string, until time.Time) error
+		Reset(ctx context.Context, scope, key string) error
+		GetLocked(ctx context.Context) ([]*LoginAttempt, error)
+	}
+	PersonalTokens interface {
+		Create(context.Context, *PersonalToken) error
+		Use(context.Context, string) (*PersonalToken, error)
+		GetByUserID(context.Context, int64) ([]*PersonalToken, error)
+		Delete(ctx context.Context, id, userID int64) error
+	}
+	Blocks interface {
+		Block(ctx context.Context, userID, blockedID int64) error
+		Unblock(ctx context.Context, userID, blockedID int64) error
+		IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+	}
+	Mutes interface {
+		Mute(ctx context.Context, userID, mutedID int64) error
+		Unmute(ctx context.Context, userID, mutedID int64) error
+		GetMuterIDs(ctx context.Context, mutedID int64) ([]int64, error)
+	}
+	Reactions interface {
+		Set(ctx context.Context, postID, userID int64, reaction string) error
+		Delete(ctx context.Context, postID, userID int64) error
+		GetSummary(ctx context.Context, postID, viewerID int64) (*ReactionSummary, error)
 	}
 }
 
 func NewStorage(db *sql.DB) *Storage {
 	return &Storage{
-		Posts:     &PostStore{db: db},
-		Users:     &UserStore{db: db},
-		Comments:  &CommentStore{db: db},
-		Followers: &FollowerStore{db: db},
-		Roles:     &RoleStore{db: db},
+		Posts:          &PostStore{db: db},
+		Users:          &UserStore{db: db},
+		Comments:       &CommentStore{db: db},
+		Followers:      &FollowerStore{db: db},
+		Roles:          &RoleStore{db: db},
+		MFA:            &MFAStore{db: db},
+		RefreshTokens:  &RefreshTokenStore{db: db},
+		RevokedTokens:  &RevokedTokenStore{db: db},
+		Identities:     &IdentityStore{db: db},
+		LoginAttempts:  &LoginAttemptStore{db: db},
+		PersonalTokens: &PersonalTokenStore{db: db},
+		Blocks:         &BlockStore{db: db},
+		Mutes:          &MuteStore{db: db},
+		Reactions:      &ReactionStore{db: db},
 	}
 }
 
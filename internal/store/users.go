@@ -11,12 +11,18 @@ import (
 )
 
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  password  `json:"-"`
-	Role      Role      `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Password    password  `json:"-"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Bio         string    `json:"bio,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	IsPrivate   bool      `json:"is_private"`
+	Role        Role      `json:"role"`
+	Active      bool      `json:"active"`
+	MFAEnabled  bool      `json:"mfa_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type password struct {
@@ -45,7 +51,7 @@ func (s *UserStore) create(ctx context.Context, trx *sql.Tx, user *User) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
 	query := `
-INSERT INTO users (username, email, password, role_id) VALUES ($1, $2, $3, $4)
+INSERT INTO users (username, email, password, role_id, active) VALUES ($1, $2, $3, $4, $5)
 RETURNING id, created_at`
 	err := trx.QueryRowContext(
 		ctx,
@@ -54,6 +60,7 @@ RETURNING id, created_at`
 		user.Email,
 		user.Password.hash,
 		user.Role.ID,
+		user.Active,
 	).Scan(
 		&user.ID,
 		&user.CreatedAt)
@@ -71,9 +78,10 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
 	query := `
-SELECT u.id, u.username, u.email, u.created_at, r.id, r.name, r.description, r.level
+SELECT u.id, u.username, u.email, u.display_name, u.bio, u.avatar_url, u.is_private, u.active, u.mfa_enabled, u.created_at,
+       r.id, r.name, r.description, r.level, r.mfa_required
 FROM users u
-JOIN roles r ON u.role_id = r.id WHERE u.id = $1`
+JOIN roles r ON u.role_id = r.id WHERE u.id = $1 AND u.deleted_at IS NULL`
 	user := &User{}
 	err := s.db.QueryRowContext(
 		ctx,
@@ -82,11 +90,18 @@ JOIN roles r ON u.role_id = r.id WHERE u.id = $1`
 		&user.ID,
 		&user.Username,
 		&user.Email,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.IsPrivate,
+		&user.Active,
+		&user.MFAEnabled,
 		&user.CreatedAt,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Description,
-		&user.Role.Level)
+		&user.Role.Level,
+		&user.Role.MFARequired)
 
 	if err != nil {
 		switch {
@@ -103,10 +118,11 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
 	query := `
-SELECT u.id, u.username, u.email, u.password, u.created_at, r.id, r.name, r.description, r.level
+SELECT u.id, u.username, u.email, u.password, u.display_name, u.bio, u.avatar_url, u.is_private, u.active, u.mfa_enabled, u.created_at,
+       r.id, r.name, r.description, r.level, r.mfa_required
 FROM users u
 JOIN roles r ON u.role_id = r.id
-WHERE u.email = $1`
+WHERE u.email = $1 AND u.deleted_at IS NULL`
 	user := &User{}
 	err := s.db.QueryRowContext(
 		ctx,
@@ -116,11 +132,18 @@ WHERE u.email = $1`
 		&user.Username,
 		&user.Email,
 		&user.Password.hash,
+		&user.DisplayName,
+		&user.Bio,
+		&user.AvatarURL,
+		&user.IsPrivate,
+		&user.Active,
+		&user.MFAEnabled,
 		&user.CreatedAt,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Description,
-		&user.Role.Level)
+		&user.Role.Level,
+		&user.Role.MFARequired)
 
 	if err != nil {
 		switch {
@@ -165,28 +188,276 @@ func (s *UserStore) createUserInvitation(ctx context.Context, trx *sql.Tx, userI
 	return nil
 }
 
-func (s *UserStore) Activate(ctx context.Context, inviteCodeHashed string) error {
-	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
-		if err := s.activate(ctx, trx, inviteCodeHashed); err != nil {
+// Activate activates the user the invitation code was issued for and returns the user's ID.
+// When the invitation confirms an email change, the email is replaced as well.
+func (s *UserStore) Activate(ctx context.Context, inviteCodeHashed string) (int64, error) {
+	var userID int64
+	err := withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		id, err := s.activate(ctx, trx, inviteCodeHashed)
+		if err != nil {
 			return err
 		}
 		if err := s.deleteInvite(ctx, trx, inviteCodeHashed); err != nil {
 			return err
 		}
+		userID = id
 		return nil
 	})
+	return userID, err
+}
+
+func (s *UserStore) activate(ctx context.Context, tx *sql.Tx, inviteCodeHashed string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+UPDATE users u SET active = TRUE, email = COALESCE(i.new_email, u.email)
+FROM user_invitation i
+WHERE i.user_id = u.id AND i.invite_code = $1 AND i.expiration_time > NOW()
+RETURNING u.id`
+	var userID int64
+	err := tx.QueryRowContext(ctx, query, inviteCodeHashed).Scan(&userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		case errors.As(err, &pgErr) && pgErr.Code == "23505":
+			return 0, ErrConflict
+		default:
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+func (s *UserStore) deleteInvite(ctx context.Context, tx *sql.Tx, inviteCodeHashed string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM user_invitation WHERE invite_code = $1`
+	_, err := tx.ExecContext(ctx, query, inviteCodeHashed)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReplaceInvitation issues a new invitation code for the user, invalidating the previous one.
+func (s *UserStore) ReplaceInvitation(ctx context.Context, userID int64, inviteCode string, expirationTime time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO user_invitation (user_id, invite_code, expiration_time) VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET invite_code = EXCLUDED.invite_code, expiration_time = EXCLUDED.expiration_time, new_email = NULL`
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		userID,
+		inviteCode,
+		time.Now().Add(expirationTime))
+	return err
 }
 
-func (s *UserStore) activate(ctx context.Context, tx *sql.Tx, inviteCodeHashed string) error {
+// RequestEmailChange issues an invitation code that replaces the email of the user with newEmail
+// once it is confirmed through Activate.
+func (s *UserStore) RequestEmailChange(ctx context.Context, userID int64, newEmail, inviteCode string, expirationTime time.Duration) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
 	query := `
-UPDATE users SET active = TRUE
+INSERT INTO user_invitation (user_id, invite_code, expiration_time, new_email) VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO UPDATE SET invite_code = EXCLUDED.invite_code, expiration_time = EXCLUDED.expiration_time, new_email = EXCLUDED.new_email`
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		userID,
+		inviteCode,
+		time.Now().Add(expirationTime),
+		newEmail)
+	return err
+}
+
+// Update saves the profile of the user, and the password when it was set. Making the account public
+// accepts the pending follow requests; the IDs of the accepted followers are returned.
+func (s *UserStore) Update(ctx context.Context, user *User) ([]int64, error) {
+	var passwordHash any
+	if user.Password.hash != nil {
+		passwordHash = user.Password.hash
+	}
+	var accepted []int64
+	err := withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+		defer cancel()
+		query := `
+UPDATE users SET username = $1, display_name = $2, bio = $3, avatar_url = $4, is_private = $5,
+                 password = COALESCE($6, password)
+WHERE id = $7`
+		res, err := trx.ExecContext(
+			ctx,
+			query,
+			user.Username,
+			user.DisplayName,
+			user.Bio,
+			user.AvatarURL,
+			user.IsPrivate,
+			passwordHash,
+			user.ID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrNotFound
+		}
+		if user.IsPrivate {
+			return nil
+		}
+		query = `
+UPDATE user_follower SET status = 'accepted', created_at = NOW()
+WHERE user_id = $1 AND status = 'pending'
+RETURNING follower_id`
+		rows, err := trx.QueryContext(ctx, query, user.ID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var followerID int64
+			if err := rows.Scan(&followerID); err != nil {
+				return err
+			}
+			accepted = append(accepted, followerID)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accepted, nil
+}
+
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, expirationTime time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+INSERT INTO password_reset (user_id, token, expiration_time) VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token, expiration_time = EXCLUDED.expiration_time`
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		userID,
+		token,
+		time.Now().Add(expirationTime))
+	return err
+}
+
+// ResetPassword consumes the password reset token and stores the password hash of user.
+// The ID of the user the token belonged to is set on user.
+func (s *UserStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		if err := s.resetPassword(ctx, trx, token, user); err != nil {
+			return err
+		}
+		return s.deletePasswordReset(ctx, trx, user.ID)
+	})
+}
+
+func (s *UserStore) resetPassword(ctx context.Context, tx *sql.Tx, token string, user *User) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+UPDATE users SET password = $1
 WHERE id = (
-	SELECT user_id FROM user_invitation
-	WHERE invite_code = $1 AND expiration_time > NOW()
-)`
-	res, err := tx.ExecContext(ctx, query, inviteCodeHashed)
+	SELECT user_id FROM password_reset
+	WHERE token = $2 AND expiration_time > NOW()
+)
+RETURNING id`
+	err := tx.QueryRowContext(ctx, query, user.Password.hash, token).Scan(&user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *UserStore) deletePasswordReset(ctx context.Context, tx *sql.Tx, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `DELETE FROM password_reset WHERE user_id = $1`
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
+const (
+	// DeletionPolicyAnonymize keeps posts and comments of a deleted user under an anonymized account.
+	DeletionPolicyAnonymize = "anonymize"
+	// DeletionPolicyCascade deletes posts and comments together with the user.
+	DeletionPolicyCascade = "cascade"
+)
+
+// Delete soft-deletes the user: personal data is erased, credentials, sessions and follows are removed,
+// and posts and comments are kept anonymized or deleted according to policy.
+func (s *UserStore) Delete(ctx context.Context, userID int64, policy string) error {
+	return withTrx(ctx, s.db, func(trx *sql.Tx) error {
+		if err := s.anonymize(ctx, trx, userID); err != nil {
+			return err
+		}
+		queries := []string{
+			`DELETE FROM user_follower WHERE user_id = $1 OR follower_id = $1`,
+			`DELETE FROM user_blocks WHERE user_id = $1 OR blocked_id = $1`,
+			`DELETE FROM user_mutes WHERE user_id = $1 OR muted_id = $1`,
+			`DELETE FROM user_invitation WHERE user_id = $1`,
+			`DELETE FROM password_reset WHERE user_id = $1`,
+			`DELETE FROM user_recovery_codes WHERE user_id = $1`,
+			`DELETE FROM user_identities WHERE user_id = $1`,
+			`DELETE FROM refresh_tokens WHERE user_id = $1`,
+			`DELETE FROM personal_tokens WHERE user_id = $1`,
+		}
+		if policy == DeletionPolicyCascade {
+			queries = append(queries,
+				`DELETE FROM post_reactions WHERE user_id = $1`,
+				`DELETE FROM comment_edits WHERE comment_id IN (SELECT id FROM comments WHERE user_id = $1)`,
+				// comments answered by others stay as tombstones to keep the threads in place
+				`UPDATE comments c SET content = '', deleted_at = NOW()
+				 WHERE c.user_id = $1 AND EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)`,
+				`DELETE FROM comments WHERE user_id = $1 AND deleted_at IS NULL`,
+				`DELETE FROM posts WHERE user_id = $1`,
+			)
+		}
+		for _, query := range queries {
+			if err := s.execForUser(ctx, trx, query, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *UserStore) anonymize(ctx context.Context, tx *sql.Tx, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
+	defer cancel()
+	query := `
+UPDATE users SET username = 'deleted-' || id,
+                 email = 'deleted-' || id || '@deleted.invalid',
+                 password = '',
+                 display_name = '',
+                 bio = '',
+                 avatar_url = '',
+                 totp_secret = NULL,
+                 mfa_enabled = FALSE,
+                 active = FALSE,
+                 deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, userID)
 	if err != nil {
 		return err
 	}
@@ -200,13 +471,9 @@ WHERE id = (
 	return nil
 }
 
-func (s *UserStore) deleteInvite(ctx context.Context, tx *sql.Tx, inviteCodeHashed string) error {
+func (s *UserStore) execForUser(ctx context.Context, tx *sql.Tx, query string, userID int64) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimoutDuration)
 	defer cancel()
-	query := `DELETE FROM user_invitation WHERE invite_code = $1`
-	_, err := tx.ExecContext(ctx, query, inviteCodeHashed)
-	if err != nil {
-		return err
-	}
-	return nil
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
 }